@@ -104,7 +104,7 @@ func (v Belement) GetList() ([]Belement, error) {
 	if err := getErrorByType(TypeList, v); err != nil {
 		return nil, err
 	} else {
-		return v.Value.([]Belement), nil
+		return resolve(v.Value).([]Belement), nil
 	}
 }
 
@@ -112,7 +112,7 @@ func (v Belement) GetDict() (map[string]Belement, error) {
 	if err := getErrorByType(TypeDict, v); err != nil {
 		return nil, err
 	} else {
-		return v.Value.(map[string]Belement), nil
+		return resolve(v.Value).(map[string]Belement), nil
 	}
 }
 
@@ -121,7 +121,7 @@ func (v Belement) GetListValue(index int) (Belement, error) {
 	if err != nil {
 		return InvalidBelement, BencodeError{msg: "Belement is not a list"}
 	}
-	if index >= len(l) {
+	if index < 0 || index >= len(l) {
 		return InvalidBelement, BencodeError{msg: fmt.Sprintf("Index %d out of range", index)}
 	}
 	return l[index], nil
@@ -198,7 +198,7 @@ func (v Belement) GetAnyList() ([]any, error) {
 	}
 	x := make([]any, 0)
 	for _, v := range l {
-		x = append(x, v.Value)
+		x = append(x, resolve(v.Value))
 	}
 	return x, nil
 }
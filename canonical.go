@@ -0,0 +1,252 @@
+package bencode
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+)
+
+// CheckCanonical reports whether data holds exactly one element in the
+// canonical encoding required by BEP 3: integers and string lengths without
+// leading zeros or "-0", dict keys in strictly ascending byte order and no
+// trailing data. The returned error is a SyntaxError carrying the offset.
+func CheckCanonical(data []byte) error {
+	s := scanner{data: data, canonical: true}
+	end, err := s.value(0)
+	if err != nil {
+		return err
+	}
+	if end != len(data) {
+		return SyntaxError{Offset: end, msg: "Trailing data after element"}
+	}
+	return nil
+}
+
+// RawValue returns the encoded bytes of the element at p without decoding
+// the document, e.g. the info dict of a torrent for computing its info hash.
+func RawValue(data []byte, p Path) ([]byte, error) {
+	s := scanner{data: data}
+	start := 0
+	for i, e := range p {
+		var err error
+		if e.IsIndex {
+			start, err = s.listIndex(start, e.Index)
+		} else {
+			start, err = s.dictKey(start, e.Key)
+		}
+		if err != nil {
+			if _, ok := err.(SyntaxError); ok {
+				return nil, err
+			}
+			return nil, BencodeError{msg: fmt.Sprintf("%s: %s", p[:i+1], err.Error())}
+		}
+	}
+	end, err := s.value(start)
+	if err != nil {
+		return nil, err
+	}
+	return data[start:end], nil
+}
+
+// MaxDepth is the deepest nesting of lists and dicts that is decoded.
+// Deeper documents are rejected with an error, as decoding them could
+// exhaust the stack.
+const MaxDepth = 10000
+
+// scanner validates elements. Unless canonical is set it accepts what Decode
+// accepts, which is the rule of every decoder in the package.
+type scanner struct {
+	data      []byte
+	canonical bool       // require the canonical encoding of BEP 3
+	index     *lazyIndex // records lists and dicts when not nil
+}
+
+// noncanonical handles an element that Decode accepts but BEP 3 forbids. It
+// is an error when s requires the canonical encoding, and otherwise noted in
+// the index so the lists and dicts around it are not copied as canonical.
+func (s scanner) noncanonical(pos int, msg string) error {
+	if s.canonical {
+		return SyntaxError{Offset: pos, msg: msg}
+	}
+	if s.index != nil {
+		s.index.noncanonical++
+	}
+	return nil
+}
+
+// value validates the element starting at pos and returns the offset just
+// past it.
+func (s scanner) value(pos int) (int, error) {
+	return s.nested(pos, 0)
+}
+
+// nested is value for an element within depth lists and dicts.
+func (s scanner) nested(pos int, depth int) (int, error) {
+	if pos >= len(s.data) {
+		return pos, SyntaxError{Offset: pos, msg: "Unexpected end of data"}
+	}
+	if c := s.data[pos]; (c == 'l' || c == 'd') && depth == MaxDepth {
+		return pos, SyntaxError{Offset: pos, msg: fmt.Sprintf("Nesting deeper than %d", MaxDepth)}
+	}
+
+	switch s.data[pos] {
+	case 'i':
+		end := bytes.IndexByte(s.data[pos:], 'e')
+		if end == -1 {
+			return pos, SyntaxError{Offset: pos, msg: "Invalid integer format: missing end of element"}
+		}
+		digits := s.data[pos+1 : pos+end]
+		_, canonical, err := parseInt(digits)
+		if err != nil {
+			return pos, SyntaxError{Offset: pos + 1, msg: err.Error()}
+		}
+		if !canonical {
+			if err := s.noncanonical(pos+1, fmt.Sprintf("Invalid integer format: %q is not canonical", digits)); err != nil {
+				return pos, err
+			}
+		}
+		return pos + end + 1, nil
+	case 'l':
+		mark := s.index.open(pos)
+		pos++
+		for {
+			if pos >= len(s.data) {
+				return pos, SyntaxError{Offset: pos, msg: "Invalid list format: missing end of list"}
+			}
+			if s.data[pos] == 'e' {
+				s.index.close(mark, pos+1)
+				return pos + 1, nil
+			}
+			var err error
+			if pos, err = s.nested(pos, depth+1); err != nil {
+				return pos, err
+			}
+		}
+	case 'd':
+		mark := s.index.open(pos)
+		pos++
+		var prev []byte
+		for i := 0; ; i++ {
+			if pos >= len(s.data) {
+				return pos, SyntaxError{Offset: pos, msg: "Invalid dict format: missing end of dict"}
+			}
+			if s.data[pos] == 'e' {
+				s.index.close(mark, pos+1)
+				return pos + 1, nil
+			}
+
+			key, next, err := s.str(pos)
+			if err != nil {
+				return pos, err
+			}
+			if (s.canonical || s.index != nil) && i > 0 && compareKeyBytes(prev, key) >= 0 {
+				msg := fmt.Sprintf("Dict key %q is not sorted after %q", key, prev)
+				if bytes.Equal(prev, key) {
+					msg = fmt.Sprintf("Duplicate dict key %q", key)
+				}
+				if err := s.noncanonical(pos, msg); err != nil {
+					return pos, err
+				}
+			}
+			prev = key
+
+			if next >= len(s.data) || s.data[next] == 'e' {
+				return next, SyntaxError{Offset: next, msg: "Invalid dict format: missing value"}
+			}
+			if pos, err = s.nested(next, depth+1); err != nil {
+				return pos, err
+			}
+		}
+	default:
+		_, end, err := s.str(pos)
+		return end, err
+	}
+}
+
+// str validates the string starting at pos and returns its contents and the
+// offset just past it.
+func (s scanner) str(pos int) ([]byte, int, error) {
+	colon := bytes.IndexByte(s.data[pos:], ':')
+	if colon == -1 {
+		return nil, pos, SyntaxError{Offset: pos, msg: "Invalid string format"}
+	}
+	digits := s.data[pos : pos+colon]
+	length, canonical, err := parseLength(digits)
+	if err != nil {
+		return nil, pos, SyntaxError{Offset: pos, msg: err.Error()}
+	}
+	if !canonical {
+		if err := s.noncanonical(pos, fmt.Sprintf("Invalid string format. Length %q is not canonical", digits)); err != nil {
+			return nil, pos, err
+		}
+	}
+	start := pos + colon + 1
+	if length > len(s.data)-start {
+		return nil, pos, SyntaxError{Offset: pos, msg: "Invalid string format. Length mismatch"}
+	}
+	return s.data[start : start+length], start + length, nil
+}
+
+func (s scanner) listIndex(pos int, index int) (int, error) {
+	if pos >= len(s.data) || s.data[pos] != 'l' {
+		return pos, BencodeError{msg: "Belement is not a list"}
+	}
+	pos++
+	for i := 0; pos < len(s.data) && s.data[pos] != 'e'; i++ {
+		if i == index {
+			return pos, nil
+		}
+		var err error
+		if pos, err = s.value(pos); err != nil {
+			return pos, err
+		}
+	}
+	return pos, BencodeError{msg: fmt.Sprintf("Index %d out of range", index)}
+}
+
+func (s scanner) dictKey(pos int, key string) (int, error) {
+	if pos >= len(s.data) || s.data[pos] != 'd' {
+		return pos, BencodeError{msg: "Belement is not a dict"}
+	}
+	pos++
+	for pos < len(s.data) && s.data[pos] != 'e' {
+		k, next, err := s.str(pos)
+		if err != nil {
+			return pos, err
+		}
+		if string(k) == key {
+			return next, nil
+		}
+		if pos, err = s.value(next); err != nil {
+			return pos, err
+		}
+	}
+	return pos, BencodeError{msg: fmt.Sprintf("Key %s not found in dict", key)}
+}
+
+// parseInt parses the digits of an integer element in any form
+// strconv.Atoi accepts, as Decode always has. canonical reports whether they
+// are also in the form BEP 3 requires, without leading zeros, "-0" or a plus
+// sign.
+func parseInt(b []byte) (v int, canonical bool, err error) {
+	v, err = strconv.Atoi(string(b))
+	if err != nil {
+		return 0, false, BencodeError{msg: fmt.Sprintf("Invalid integer format: %s", err.Error())}
+	}
+	var buf [20]byte
+	return v, bytes.Equal(strconv.AppendInt(buf[:0], int64(v), 10), b), nil
+}
+
+// parseLength parses the length of a string like parseInt, rejecting
+// negative lengths.
+func parseLength(b []byte) (n int, canonical bool, err error) {
+	n, err = strconv.Atoi(string(b))
+	if err != nil {
+		return 0, false, BencodeError{msg: fmt.Sprintf("Invalid string format. Invalid length: %s", err.Error())}
+	}
+	if n < 0 {
+		return 0, false, BencodeError{msg: fmt.Sprintf("Invalid string format. Negative length %d", n)}
+	}
+	var buf [20]byte
+	return n, bytes.Equal(strconv.AppendInt(buf[:0], int64(n), 10), b), nil
+}
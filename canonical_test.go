@@ -0,0 +1,75 @@
+package bencode_test
+
+import (
+	"testing"
+
+	"github.com/deathcrafter/bencode"
+)
+
+func TestCheckCanonical(t *testing.T) {
+	for _, data := range []string{
+		"i0e",
+		"i-12e",
+		"0:",
+		"le",
+		"d1:ai1e1:bli1eee",
+		"d0:i1e1:a0:e",
+	} {
+		if err := bencode.CheckCanonical([]byte(data)); err != nil {
+			t.Fatalf("%q: %s", data, err)
+		}
+	}
+}
+
+func TestCheckCanonicalInvalid(t *testing.T) {
+	for data, offset := range map[string]int{
+		"i-0e":           1,
+		"i03e":           1,
+		"ie":             1,
+		"i+1e":           1,
+		"03:abc":         0,
+		"i1ei2e":         3,
+		"d1:bi1e1:ai2ee": 7,
+		"d1:ai1e1:ai2ee": 7,
+		"li1e":           4,
+		"d1:ae":          4,
+		"l5:abce":        1,
+	} {
+		err := bencode.CheckCanonical([]byte(data))
+		if err == nil {
+			t.Fatalf("Expected error for %q, got nil", data)
+		}
+		serr, ok := err.(bencode.SyntaxError)
+		if !ok {
+			t.Fatalf("Expected SyntaxError, got %T", err)
+		}
+		if serr.Offset != offset {
+			t.Fatalf("%q: expected offset %d, got %d (%s)", data, offset, serr.Offset, err)
+		}
+		t.Log(err)
+	}
+}
+
+func TestRawValue(t *testing.T) {
+	data := []byte("d8:announce3:url4:infod6:lengthi1e4:name1:xe4:listl1:a1:bee")
+
+	raw, err := bencode.RawValue(data, bencode.Path{}.Key("info"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(raw) != "d6:lengthi1e4:name1:xe" {
+		t.Fatalf("Expected %s, got %s", "d6:lengthi1e4:name1:xe", string(raw))
+	}
+
+	raw, err = bencode.RawValue(data, bencode.Path{}.Key("list").Index(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(raw) != "1:b" {
+		t.Fatalf("Expected %s, got %s", "1:b", string(raw))
+	}
+
+	if _, err := bencode.RawValue(data, bencode.Path{}.Key("missing")); err == nil {
+		t.Fatal("Expected error, got nil")
+	}
+}
@@ -0,0 +1,407 @@
+// Command bencode inspects and edits bencoded files such as torrents.
+//
+// Usage:
+//
+//	bencode dump [-depth n] [-width n] [file]
+//	bencode json [-r] [file]
+//	bencode get [-r] <path> [file]
+//	bencode set [-s] [-o output] <path> <value> [file]
+//	bencode validate [file]
+//	bencode infohash [-v2] [file]
+//	bencode diff <file> <file>
+//
+// The file defaults to standard input. Paths use the jq like syntax of
+// bencode.ParsePath, e.g. .info.name or .announce-list[0][0]. Values given to
+// set are JSON in the mapping of bencode.FromJSON unless -s is used. Set
+// keeps every byte outside the value it changes, so a torrent keeps its info
+// hash unless the value is within info, even if the file is not canonical.
+package main
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/deathcrafter/bencode"
+)
+
+const usage = `usage: bencode <command> [arguments]
+
+commands:
+  dump [-depth n] [-width n] [file]        print a tree view
+  json [-r] [file]                         convert to JSON, or from JSON with -r
+  get [-r] <path> [file]                   print the value at path as JSON
+  set [-s] [-o output] <path> <value> [file]
+                                           replace the value at path
+  validate [file]                          check for canonical encoding
+  infohash [-v2] [file]                    print the info hash of a torrent
+  diff <file> <file>                       list the changes between two files
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+
+	commands := map[string]func([]string, io.Reader, io.Writer) error{
+		"dump":     dump,
+		"json":     toJSON,
+		"get":      get,
+		"set":      set,
+		"validate": validate,
+		"infohash": infohash,
+		"diff":     diff,
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "bencode: unknown command %q\n%s", args[0], usage)
+		return 2
+	}
+
+	err := cmd(args[1:], stdin, stdout)
+	if err == errDifferent {
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "bencode %s: %s\n", args[0], err)
+		if _, ok := err.(usageError); ok {
+			return 2
+		}
+		return 1
+	}
+	return 0
+}
+
+// errDifferent makes diff exit with status 1 without printing an error.
+var errDifferent = errors.New("files differ")
+
+type usageError string
+
+func (e usageError) Error() string {
+	return string(e)
+}
+
+func parseFlags(fs *flag.FlagSet, args []string, positional int) ([]string, error) {
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse(args); err != nil {
+		return nil, usageError(err.Error())
+	}
+	rest := fs.Args()
+	if len(rest) < positional || len(rest) > positional+1 {
+		return nil, usageError(fmt.Sprintf("expected %d arguments and an optional file, got %d", positional, len(rest)))
+	}
+	return rest, nil
+}
+
+func readInput(rest []string, positional int, stdin io.Reader) ([]byte, error) {
+	if len(rest) > positional && rest[positional] != "-" {
+		return os.ReadFile(rest[positional])
+	}
+	return io.ReadAll(stdin)
+}
+
+func decodeInput(rest []string, positional int, stdin io.Reader) (bencode.Belement, error) {
+	data, err := readInput(rest, positional, stdin)
+	if err != nil {
+		return bencode.InvalidBelement, err
+	}
+	return bencode.Decode(data)
+}
+
+func dump(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("dump", flag.ContinueOnError)
+	depth := fs.Int("depth", 0, "collapse containers nested deeper than this")
+	width := fs.Int("width", bencode.DefaultPrintOptions.MaxWidth, "cut strings after this many characters")
+	rest, err := parseFlags(fs, args, 0)
+	if err != nil {
+		return err
+	}
+	b, err := decodeInput(rest, 0, stdin)
+	if err != nil {
+		return err
+	}
+	o := bencode.PrintOptions{MaxDepth: *depth, MaxWidth: *width}
+	_, err = io.WriteString(stdout, o.Sprint(b)+"\n")
+	return err
+}
+
+func toJSON(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("json", flag.ContinueOnError)
+	reverse := fs.Bool("r", false, "convert JSON to bencode")
+	rest, err := parseFlags(fs, args, 0)
+	if err != nil {
+		return err
+	}
+	data, err := readInput(rest, 0, stdin)
+	if err != nil {
+		return err
+	}
+
+	if *reverse {
+		b, err := bencode.FromJSON(data)
+		if err != nil {
+			return err
+		}
+		e, err := b.Encode()
+		if err != nil {
+			return err
+		}
+		_, err = stdout.Write(e)
+		return err
+	}
+
+	b, err := bencode.Decode(data)
+	if err != nil {
+		return err
+	}
+	j, err := bencode.ToJSON(b)
+	if err != nil {
+		return err
+	}
+	_, err = stdout.Write(append(j, '\n'))
+	return err
+}
+
+func get(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("get", flag.ContinueOnError)
+	raw := fs.Bool("r", false, "print strings without JSON quoting")
+	rest, err := parseFlags(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	p, err := bencode.ParsePath(rest[0])
+	if err != nil {
+		return usageError(err.Error())
+	}
+	b, err := decodeInput(rest, 1, stdin)
+	if err != nil {
+		return err
+	}
+
+	v, err := b.Get(p)
+	if err != nil {
+		return err
+	}
+	if s, err := v.GetString(); err == nil && *raw {
+		_, err = io.WriteString(stdout, s)
+		return err
+	}
+	j, err := bencode.ToJSON(v)
+	if err != nil {
+		return err
+	}
+	_, err = stdout.Write(append(j, '\n'))
+	return err
+}
+
+func set(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("set", flag.ContinueOnError)
+	literal := fs.Bool("s", false, "treat the value as a literal string")
+	output := fs.String("o", "", "write to this file instead of rewriting the input")
+	rest, err := parseFlags(fs, args, 2)
+	if err != nil {
+		return err
+	}
+	p, err := bencode.ParsePath(rest[0])
+	if err != nil {
+		return usageError(err.Error())
+	}
+
+	value := bencode.Belement{Type: bencode.TypeString, Value: rest[1]}
+	if !*literal {
+		if value, err = bencode.FromJSON([]byte(rest[1])); err != nil {
+			return fmt.Errorf("%s (use -s for a literal string)", err)
+		}
+	}
+
+	data, err := readInput(rest, 2, stdin)
+	if err != nil {
+		return err
+	}
+	b, err := bencode.Decode(data)
+	if err != nil {
+		return err
+	}
+	// Set reports a path that cannot be set, and splice does the edit
+	if _, err = b.Set(p, value); err != nil {
+		return err
+	}
+	v, err := value.Encode()
+	if err != nil {
+		return err
+	}
+	e, err := splice(data, p, v)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case *output != "":
+		return os.WriteFile(*output, e, 0o644)
+	case len(rest) > 2 && rest[2] != "-":
+		return os.WriteFile(rest[2], e, 0o644)
+	default:
+		_, err = stdout.Write(e)
+		return err
+	}
+}
+
+// splice returns data with the encoded value put at p, replacing the element
+// there or adding it where Set would. The rest of data is copied unchanged,
+// and a new dict key goes before the first greater key so a sorted dict
+// stays sorted. Elements are read with bencode.Scanner, which accepts what
+// bencode.Decode accepts, so padded integers elsewhere are kept as they are.
+func splice(data []byte, p bencode.Path, value []byte) ([]byte, error) {
+	s := bencode.NewScanner(data)
+	for i, e := range p {
+		last := i == len(p)-1
+		if e.IsIndex {
+			if err := s.List(); err != nil {
+				return nil, err
+			}
+			for n := 0; n < e.Index; n++ {
+				if !s.More() {
+					return nil, fmt.Errorf("%s: Index %d out of range", p[:i+1], e.Index)
+				}
+				if err := s.Skip(); err != nil {
+					return nil, err
+				}
+			}
+			if last && !s.More() {
+				return insert(data, s.Offset(), value), nil
+			}
+			continue
+		}
+
+		if err := s.Dict(); err != nil {
+			return nil, err
+		}
+		// the key may come anywhere in a dict that is not sorted, so the
+		// whole dict is searched before adding it
+		at := -1
+		for {
+			if !s.More() {
+				if !last {
+					return nil, fmt.Errorf("%s: Key %s not found in dict", p[:i+1], e.Key)
+				}
+				if at == -1 {
+					at = s.Offset()
+				}
+				return insert(data, at, bencode.AppendString(nil, e.Key), value), nil
+			}
+			offset := s.Offset()
+			k, err := s.Bytes()
+			if err != nil {
+				return nil, err
+			}
+			if string(k) == e.Key {
+				break
+			}
+			if at == -1 && string(k) > e.Key {
+				at = offset
+			}
+			if err := s.Skip(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	start := s.Offset()
+	if err := s.Skip(); err != nil {
+		return nil, err
+	}
+	out := append(data[:start:start], value...)
+	return append(out, data[s.Offset():]...), nil
+}
+
+// insert returns data with parts inserted at offset.
+func insert(data []byte, offset int, parts ...[]byte) []byte {
+	out := append([]byte{}, data[:offset]...)
+	for _, part := range parts {
+		out = append(out, part...)
+	}
+	return append(out, data[offset:]...)
+}
+
+func validate(args []string, stdin io.Reader, stdout io.Writer) error {
+	rest, err := parseFlags(flag.NewFlagSet("validate", flag.ContinueOnError), args, 0)
+	if err != nil {
+		return err
+	}
+	data, err := readInput(rest, 0, stdin)
+	if err != nil {
+		return err
+	}
+	if err := bencode.CheckCanonical(data); err != nil {
+		return err
+	}
+	_, err = io.WriteString(stdout, "ok\n")
+	return err
+}
+
+func infohash(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("infohash", flag.ContinueOnError)
+	v2 := fs.Bool("v2", false, "print the SHA-256 info hash of a v2 torrent")
+	rest, err := parseFlags(fs, args, 0)
+	if err != nil {
+		return err
+	}
+	data, err := readInput(rest, 0, stdin)
+	if err != nil {
+		return err
+	}
+
+	info, err := bencode.RawValue(data, bencode.Path{}.Key("info"))
+	if err != nil {
+		return err
+	}
+	var sum []byte
+	if *v2 {
+		h := sha256.Sum256(info)
+		sum = h[:]
+	} else {
+		h := sha1.Sum(info)
+		sum = h[:]
+	}
+	_, err = io.WriteString(stdout, hex.EncodeToString(sum)+"\n")
+	return err
+}
+
+func diff(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse(args); err != nil {
+		return usageError(err.Error())
+	}
+	if fs.NArg() != 2 {
+		return usageError(fmt.Sprintf("expected 2 files, got %d", fs.NArg()))
+	}
+
+	a, err := decodeInput(fs.Args(), 0, stdin)
+	if err != nil {
+		return err
+	}
+	b, err := decodeInput(fs.Args(), 1, stdin)
+	if err != nil {
+		return err
+	}
+
+	changes := bencode.Diff(a, b)
+	if len(changes) == 0 {
+		return nil
+	}
+	if _, err := io.WriteString(stdout, bencode.FormatDiff(changes)); err != nil {
+		return err
+	}
+	return errDifferent
+}
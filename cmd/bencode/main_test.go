@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const torrent = "d8:announce15:http://tracker/4:infod6:lengthi12e4:name5:a.txt12:piece lengthi16384e6:pieces20:aaaaaaaaaaaaaaaaaaaaee"
+
+func runCommand(t *testing.T, stdin string, args ...string) (string, string, int) {
+	stdout, stderr := bytes.Buffer{}, bytes.Buffer{}
+	code := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return stdout.String(), stderr.String(), code
+}
+
+func TestGet(t *testing.T) {
+	out, errOut, code := runCommand(t, torrent, "get", ".info.name")
+	if code != 0 {
+		t.Fatal(errOut)
+	}
+	if out != "\"a.txt\"\n" {
+		t.Fatalf("Expected %q, got %q", "\"a.txt\"\n", out)
+	}
+
+	out, _, _ = runCommand(t, torrent, "get", "-r", `.info["piece length"]`)
+	if out != "16384\n" {
+		t.Fatalf("Expected %q, got %q", "16384\n", out)
+	}
+
+	_, errOut, code = runCommand(t, torrent, "get", ".info.missing")
+	if code != 1 {
+		t.Fatalf("Expected exit code 1, got %d", code)
+	}
+	t.Log(errOut)
+}
+
+func TestSet(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "a.torrent")
+	if err := os.WriteFile(file, []byte(torrent), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, errOut, code := runCommand(t, "", "set", ".info.name", `"b.txt"`, file); code != 0 {
+		t.Fatal(errOut)
+	}
+	if _, errOut, code := runCommand(t, "", "set", "-s", ".comment", "hello", file); code != 0 {
+		t.Fatal(errOut)
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "d8:announce15:http://tracker/7:comment5:hello4:infod6:lengthi12e4:name5:b.txt12:piece lengthi16384e6:pieces20:aaaaaaaaaaaaaaaaaaaaee"
+	if string(data) != expected {
+		t.Fatalf("Expected %s, got %s", expected, string(data))
+	}
+}
+
+func TestSetInPlace(t *testing.T) {
+	// an info dict with unsorted keys
+	const unsorted = "d4:infod4:name1:a6:lengthi1ee4:listli1eee"
+	hash, _, _ := runCommand(t, unsorted, "infohash")
+
+	for _, c := range []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"-s", ".comment", "x"}, "d7:comment1:x4:infod4:name1:a6:lengthi1ee4:listli1eee"},
+		{[]string{"-s", ".zzz", "x"}, "d4:infod4:name1:a6:lengthi1ee4:listli1ee3:zzz1:xe"},
+		{[]string{".list[1]", "2"}, "d4:infod4:name1:a6:lengthi1ee4:listli1ei2eee"},
+		{[]string{".list[0]", "[]"}, "d4:infod4:name1:a6:lengthi1ee4:listlleee"},
+		{[]string{"-s", ".info.name", "bb"}, "d4:infod4:name2:bb6:lengthi1ee4:listli1eee"},
+		{[]string{".info.length", "2"}, "d4:infod4:name1:a6:lengthi2ee4:listli1eee"},
+		{[]string{".info.a", "1"}, "d4:infod1:ai1e4:name1:a6:lengthi1ee4:listli1eee"},
+	} {
+		out, errOut, code := runCommand(t, unsorted, append([]string{"set"}, c.args...)...)
+		if code != 0 {
+			t.Fatal(errOut)
+		}
+		if out != c.expected {
+			t.Fatalf("%v: expected %q, got %q", c.args, c.expected, out)
+		}
+	}
+
+	// edits outside info keep the info hash
+	out, _, _ := runCommand(t, unsorted, "set", "-s", ".comment", "x")
+	if h, _, _ := runCommand(t, out, "infohash"); h != hash {
+		t.Fatalf("Info hash changed from %s to %s", hash, h)
+	}
+
+	// a key that exists later in an unsorted dict is replaced, not added
+	out, errOut, code := runCommand(t, "d1:bi1e1:ai2ee", "set", ".a", "3")
+	if code != 0 || out != "d1:bi1e1:ai3ee" {
+		t.Fatalf("Expected %q, got %q (%d) %s", "d1:bi1e1:ai3ee", out, code, errOut)
+	}
+
+	// non-canonical integers are kept as they are
+	for _, c := range []struct{ data, path, expected string }{
+		{"d1:ai03e1:bi1ee", ".b", "d1:ai03e1:bi2ee"},
+		{"d1:ai03e1:bi1ee", ".a", "d1:ai2e1:bi1ee"},
+		{"d1:ai03e1:bli03eee", ".b[1]", "d1:ai03e1:bli03ei2eee"},
+	} {
+		out, errOut, code = runCommand(t, c.data, "set", c.path, "2")
+		if code != 0 || out != c.expected {
+			t.Fatalf("%s: expected %q, got %q (%d) %s", c.path, c.expected, out, code, errOut)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	out, _, code := runCommand(t, torrent, "validate")
+	if code != 0 || out != "ok\n" {
+		t.Fatalf("Expected ok, got %q (%d)", out, code)
+	}
+
+	_, errOut, code := runCommand(t, "d1:bi1e1:ai2ee", "validate")
+	if code != 1 || !strings.Contains(errOut, "offset 7") {
+		t.Fatalf("Expected positioned error, got %q (%d)", errOut, code)
+	}
+}
+
+func TestInfohash(t *testing.T) {
+	out, errOut, code := runCommand(t, torrent, "infohash")
+	if code != 0 {
+		t.Fatal(errOut)
+	}
+	if out != "791a8e76334bf1d68096abc1a56e6c0cca72ed89\n" {
+		t.Fatalf("Unexpected info hash %q", out)
+	}
+
+	// the hash is of the info dict as written, padded integers included
+	info := "d6:lengthi03e4:name1:ae"
+	out, errOut, code = runCommand(t, "d4:info"+info+"e", "infohash")
+	if code != 0 {
+		t.Fatal(errOut)
+	}
+	if expected := fmt.Sprintf("%x\n", sha1.Sum([]byte(info))); out != expected {
+		t.Fatalf("Expected info hash %q, got %q", expected, out)
+	}
+}
+
+func TestJSONAndDump(t *testing.T) {
+	out, errOut, code := runCommand(t, "d1:ali1e2:\xff\xfeee", "json")
+	if code != 0 {
+		t.Fatal(errOut)
+	}
+	if out != "{\"a\":[1,{\"$bytes\":\"//4=\"}]}\n" {
+		t.Fatalf("Unexpected JSON %q", out)
+	}
+
+	back, errOut, code := runCommand(t, out, "json", "-r")
+	if code != 0 {
+		t.Fatal(errOut)
+	}
+	if back != "d1:ali1e2:\xff\xfeee" {
+		t.Fatalf("Unexpected bencode %q", back)
+	}
+
+	out, errOut, code = runCommand(t, torrent, "dump")
+	if code != 0 {
+		t.Fatal(errOut)
+	}
+	t.Log("\n" + out)
+}
+
+func TestUsage(t *testing.T) {
+	if _, _, code := runCommand(t, "", "frobnicate"); code != 2 {
+		t.Fatalf("Expected exit code 2, got %d", code)
+	}
+	if _, _, code := runCommand(t, "", "get"); code != 2 {
+		t.Fatalf("Expected exit code 2, got %d", code)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	os.WriteFile(a, []byte("d1:ai1e1:bi2ee"), 0o644)
+	os.WriteFile(b, []byte("d1:ai1e1:bi3ee"), 0o644)
+
+	out, errOut, code := runCommand(t, "", "diff", a, b)
+	if code != 1 || out != "~ .b: 2 -> 3\n" {
+		t.Fatalf("Unexpected diff %q %q (%d)", out, errOut, code)
+	}
+
+	out, _, code = runCommand(t, "", "diff", a, a)
+	if code != 0 || out != "" {
+		t.Fatalf("Expected no differences, got %q (%d)", out, code)
+	}
+}
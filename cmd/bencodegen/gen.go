@@ -0,0 +1,768 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/deathcrafter/bencode"
+)
+
+const bencodePath = "github.com/deathcrafter/bencode"
+
+type kind int
+
+const (
+	kindString kind = iota
+	kindBytes
+	kindByteArray
+	kindInt
+	kindUint
+	kindBool
+	kindBelement
+	kindStruct // a type generated in the same run
+	kindPointer
+	kindSlice
+	kindOther // left to bencode.AppendValue and bencode.Unmarshal
+)
+
+// typ is a field type as far as the generated code needs to know it.
+type typ struct {
+	kind  kind
+	name  string // Go source of the type, for conversions and literals
+	basic string // the underlying predeclared type of ints and uints
+	elem  *typ   // of pointers and slices
+
+	// notEmpty is the omitempty test of kindOther as a format taking the
+	// value, and nilable is set for kindOther values that are left out
+	// when nil.
+	notEmpty string
+	nilable  bool
+}
+
+// field mirrors the field type of the bencode package.
+type field struct {
+	name      string // Go field path, for error messages
+	expr      string // selector from the receiver, e.g. x.common.Name
+	key       string
+	typ       *typ
+	omitEmpty bool
+	required  bool
+	def       string // Go literal of the default, if any
+}
+
+type structType struct {
+	name   string
+	fields []field // in key order
+	extra  *field
+}
+
+// decl is a type declared in the package.
+type decl struct {
+	expr    ast.Expr
+	bencode string // name the declaring file imports the bencode package as
+}
+
+type generator struct {
+	decls     map[string]decl
+	generated map[string]bool
+	imports   map[string]bool
+	buf       *bytes.Buffer
+	vars      int
+	usesErr   bool // whether the encoder being written needs err
+}
+
+func generate(dir string, names []string, output string) ([]byte, error) {
+	g := generator{
+		decls:     make(map[string]decl),
+		generated: make(map[string]bool),
+		imports:   make(map[string]bool),
+		buf:       new(bytes.Buffer),
+	}
+	pkg, err := g.load(dir, output)
+	if err != nil {
+		return nil, err
+	}
+
+	structs := make([]structType, 0, len(names))
+	for _, name := range names {
+		g.generated[name] = true
+	}
+	for _, name := range names {
+		d, ok := g.decls[name]
+		if !ok {
+			return nil, fmt.Errorf("type %s not found in %s", name, dir)
+		}
+		st, ok := d.expr.(*ast.StructType)
+		if !ok {
+			return nil, fmt.Errorf("type %s is not a struct", name)
+		}
+		s := structType{name: name}
+		if err := g.collectFields(st, d.bencode, "x.", name+".", &s); err != nil {
+			return nil, err
+		}
+		slices.SortFunc(s.fields, func(a, b field) int {
+			return bencode.CompareKeys(a.key, b.key)
+		})
+		for i := 1; i < len(s.fields); i++ {
+			if s.fields[i].key == s.fields[i-1].key {
+				return nil, fmt.Errorf("duplicate key %q in %s: fields %s and %s", s.fields[i].key, name, s.fields[i-1].name, s.fields[i].name)
+			}
+		}
+		structs = append(structs, s)
+	}
+
+	for _, s := range structs {
+		g.encoder(s)
+		g.decoder(s)
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by bencodegen; DO NOT EDIT.\n\npackage %s\n\nimport (\n", pkg)
+	imports := make([]string, 0, len(g.imports))
+	for path := range g.imports {
+		if path != bencodePath {
+			imports = append(imports, path)
+		}
+	}
+	slices.Sort(imports)
+	for _, path := range imports {
+		fmt.Fprintf(&out, "\t%q\n", path)
+	}
+	fmt.Fprintf(&out, "\n\t%q\n)\n", bencodePath)
+	out.Write(g.buf.Bytes())
+
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %s", err)
+	}
+	return src, nil
+}
+
+// load parses the package in dir, leaving out tests and the output file,
+// and records its type declarations.
+func (g *generator) load(dir string, output string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+
+	fset := token.NewFileSet()
+	pkg := ""
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") || name == output {
+			continue
+		}
+		f, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.SkipObjectResolution)
+		if err != nil {
+			return "", err
+		}
+		if pkg != "" && f.Name.Name != pkg {
+			return "", fmt.Errorf("found packages %s and %s in %s", pkg, f.Name.Name, dir)
+		}
+		pkg = f.Name.Name
+
+		bencodeName := ""
+		for _, imp := range f.Imports {
+			if path, _ := strconv.Unquote(imp.Path.Value); path == bencodePath {
+				bencodeName = "bencode"
+				if imp.Name != nil {
+					bencodeName = imp.Name.Name
+				}
+			}
+		}
+		for _, d := range f.Decls {
+			gd, ok := d.(*ast.GenDecl)
+			if !ok || gd.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gd.Specs {
+				ts := spec.(*ast.TypeSpec)
+				if ts.TypeParams == nil && !ts.Assign.IsValid() {
+					g.decls[ts.Name.Name] = decl{ts.Type, bencodeName}
+				}
+			}
+		}
+	}
+	if pkg == "" {
+		return "", fmt.Errorf("no Go files in %s", dir)
+	}
+	return pkg, nil
+}
+
+// collectFields follows collectFields of the bencode package.
+func (g *generator) collectFields(st *ast.StructType, bencodeName string, expr string, prefix string, s *structType) error {
+	for _, af := range st.Fields.List {
+		tag := ""
+		if af.Tag != nil {
+			t, _ := strconv.Unquote(af.Tag.Value)
+			tag = reflect.StructTag(t).Get("bencode")
+		}
+		if tag == "-" {
+			continue
+		}
+
+		names := make([]string, 0, len(af.Names))
+		for _, n := range af.Names {
+			names = append(names, n.Name)
+		}
+		embedded := len(names) == 0
+		if embedded {
+			names = append(names, embeddedName(af.Type))
+		}
+
+		for _, name := range names {
+			if !token.IsExported(name) && !embedded {
+				continue
+			}
+			f := field{name: prefix + name, expr: expr + name}
+			key, opts := tag, ""
+			if comma := strings.IndexByte(tag, ','); comma != -1 {
+				key, opts = tag[:comma], tag[comma+1:]
+			}
+			local, isLocalStruct := g.localStruct(af.Type)
+			inline := embedded && key == "" && isLocalStruct
+			if _, ok := af.Type.(*ast.SelectorExpr); ok && embedded && key == "" {
+				// bencode.Marshal inlines it if it is a struct, which only
+				// its package knows
+				return fmt.Errorf("embedded field %s needs a key", f.name)
+			}
+			_, isPtr := af.Type.(*ast.StarExpr)
+			if isPtr && embedded && key == "" && token.IsExported(name) {
+				// bencode.Marshal inlines pointers to structs, which would
+				// need nil checks that generated code does not have
+				return fmt.Errorf("embedded pointer field %s needs a key", f.name)
+			}
+			extra := false
+			if key == "" {
+				key = name
+			}
+
+			def := ""
+			for opts != "" {
+				opt := opts
+				if strings.HasPrefix(opts, "default=") {
+					opts = ""
+				} else if comma := strings.IndexByte(opts, ','); comma != -1 {
+					opt, opts = opts[:comma], opts[comma+1:]
+				} else {
+					opts = ""
+				}
+
+				switch {
+				case opt == "omitempty":
+					f.omitEmpty = true
+				case opt == "required":
+					f.required = true
+				case opt == "inline":
+					inline = true
+				case opt == "extra":
+					extra = true
+				case strings.HasPrefix(opt, "default="):
+					def = opt[len("default="):]
+				default:
+					return fmt.Errorf("unknown option %q for field %s", opt, f.name)
+				}
+			}
+
+			if inline {
+				if isPtr {
+					return fmt.Errorf("cannot inline pointer field %s", f.name)
+				}
+				if !isLocalStruct {
+					return fmt.Errorf("cannot inline field %s: not a struct declared in the package", f.name)
+				}
+				if err := g.collectFields(local, g.decls[embeddedName(af.Type)].bencode, f.expr+".", f.name+".", s); err != nil {
+					return err
+				}
+				continue
+			}
+			if !token.IsExported(name) {
+				continue
+			}
+
+			if extra {
+				if !isBelementMap(af.Type, bencodeName) {
+					return fmt.Errorf("extra field %s must be map[string]bencode.Belement", f.name)
+				}
+				if s.extra != nil {
+					return fmt.Errorf("fields %s and %s are both tagged extra", s.extra.name, f.name)
+				}
+				s.extra = &f
+				continue
+			}
+
+			f.key = key
+			f.typ = g.resolve(af.Type, bencodeName)
+			if def != "" {
+				lit, err := defaultLiteral(f.typ, def)
+				if err != nil {
+					return fmt.Errorf("invalid default for field %s: %s", f.name, err)
+				}
+				f.def = lit
+			}
+			s.fields = append(s.fields, f)
+		}
+	}
+	return nil
+}
+
+func embeddedName(e ast.Expr) string {
+	switch t := e.(type) {
+	case *ast.StarExpr:
+		return embeddedName(t.X)
+	case *ast.SelectorExpr:
+		return t.Sel.Name
+	case *ast.Ident:
+		return t.Name
+	}
+	return ""
+}
+
+// localStruct returns the declaration of e if it names a struct type of the
+// package.
+func (g *generator) localStruct(e ast.Expr) (*ast.StructType, bool) {
+	id, ok := e.(*ast.Ident)
+	if !ok {
+		return nil, false
+	}
+	st, ok := g.decls[id.Name].expr.(*ast.StructType)
+	return st, ok
+}
+
+func isBelementMap(e ast.Expr, bencodeName string) bool {
+	m, ok := e.(*ast.MapType)
+	if !ok {
+		return false
+	}
+	k, ok := m.Key.(*ast.Ident)
+	return ok && k.Name == "string" && isBelement(m.Value, bencodeName)
+}
+
+func isBelement(e ast.Expr, bencodeName string) bool {
+	sel, ok := e.(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	x, ok := sel.X.(*ast.Ident)
+	return ok && bencodeName != "" && x.Name == bencodeName && sel.Sel.Name == "Belement"
+}
+
+var basicKinds = map[string]kind{
+	"string":  kindString,
+	"bool":    kindBool,
+	"int":     kindInt,
+	"int8":    kindInt,
+	"int16":   kindInt,
+	"int32":   kindInt,
+	"int64":   kindInt,
+	"rune":    kindInt,
+	"uint":    kindUint,
+	"uint8":   kindUint,
+	"uint16":  kindUint,
+	"uint32":  kindUint,
+	"uint64":  kindUint,
+	"uintptr": kindUint,
+	"byte":    kindUint,
+}
+
+func (g *generator) resolve(e ast.Expr, bencodeName string) *typ {
+	other := &typ{kind: kindOther, notEmpty: "!reflect.ValueOf(%s).IsZero()"}
+
+	switch t := e.(type) {
+	case *ast.Ident:
+		if k, ok := basicKinds[t.Name]; ok {
+			return &typ{kind: k, name: t.Name, basic: t.Name}
+		}
+		if g.generated[t.Name] {
+			return &typ{kind: kindStruct, name: t.Name}
+		}
+		d, ok := g.decls[t.Name]
+		if !ok {
+			return other
+		}
+		// named types keep their name but are handled like the type
+		// they are declared as, unless that is left to reflection
+		under := g.resolve(d.expr, d.bencode)
+		switch under.kind {
+		case kindString, kindBytes, kindByteArray, kindInt, kindUint, kindBool, kindSlice:
+			named := *under
+			named.name = t.Name
+			return &named
+		}
+		return other
+	case *ast.SelectorExpr:
+		if isBelement(t, bencodeName) {
+			return &typ{kind: kindBelement, name: "bencode.Belement"}
+		}
+		return other
+	case *ast.StarExpr:
+		elem := g.resolve(t.X, bencodeName)
+		switch elem.kind {
+		case kindOther, kindPointer, kindBelement:
+			return &typ{kind: kindOther, notEmpty: "%s != nil", nilable: true}
+		}
+		return &typ{kind: kindPointer, name: "*" + elem.name, elem: elem}
+	case *ast.ArrayType:
+		if id, ok := t.Elt.(*ast.Ident); ok && (id.Name == "byte" || id.Name == "uint8") {
+			if t.Len == nil {
+				return &typ{kind: kindBytes, name: "[]byte"}
+			}
+			return &typ{kind: kindByteArray, name: "[" + types.ExprString(t.Len) + "]byte"}
+		}
+		if t.Len != nil {
+			return &typ{kind: kindOther, notEmpty: "len(%s) != 0"}
+		}
+		elem := g.resolve(t.Elt, bencodeName)
+		if elem.kind == kindOther {
+			return &typ{kind: kindOther, notEmpty: "len(%s) != 0"}
+		}
+		return &typ{kind: kindSlice, name: "[]" + elem.name, elem: elem}
+	case *ast.MapType:
+		return &typ{kind: kindOther, notEmpty: "len(%s) != 0"}
+	case *ast.InterfaceType:
+		return &typ{kind: kindOther, notEmpty: "%s != nil", nilable: true}
+	}
+	return other
+}
+
+// defaultLiteral follows parseDefault of the bencode package.
+func defaultLiteral(t *typ, s string) (string, error) {
+	switch t.kind {
+	case kindString:
+		return strconv.Quote(s), nil
+	case kindBytes:
+		return "[]byte(" + strconv.Quote(s) + ")", nil
+	case kindBool:
+		b, err := strconv.ParseBool(s)
+		return strconv.FormatBool(b), err
+	case kindInt:
+		i, err := strconv.ParseInt(s, 10, bits(t.basic))
+		return strconv.FormatInt(i, 10), err
+	case kindUint:
+		u, err := strconv.ParseUint(s, 10, bits(t.basic))
+		return strconv.FormatUint(u, 10), err
+	}
+	return "", fmt.Errorf("type %s has no default", t.name)
+}
+
+func bits(basic string) int {
+	switch basic {
+	case "int8", "uint8", "byte":
+		return 8
+	case "int16", "uint16":
+		return 16
+	case "int32", "uint32", "rune":
+		return 32
+	}
+	return 64
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(g.buf, format, args...)
+}
+
+func (g *generator) newVar(prefix string) string {
+	g.vars++
+	return prefix + strconv.Itoa(g.vars)
+}
+
+// paren makes a dereference safe to index or slice.
+func paren(expr string) string {
+	if strings.HasPrefix(expr, "*") {
+		return "(" + expr + ")"
+	}
+	return expr
+}
+
+// convert converts expr of type t to the predeclared type basic, if they
+// differ.
+func convert(basic string, expr string, t *typ) string {
+	if t.name == basic {
+		return expr
+	}
+	return basic + "(" + expr + ")"
+}
+
+// present returns the condition for the value to be written at all, or ""
+// when it always is. Nil pointers and invalid Belements are left out like
+// bencode.Marshal does.
+func present(expr string, t *typ) string {
+	switch {
+	case t.kind == kindPointer, t.kind == kindOther && t.nilable:
+		return expr + " != nil"
+	case t.kind == kindBelement:
+		return expr + ".Type != bencode.TypeInvalid"
+	}
+	return ""
+}
+
+func (g *generator) notEmpty(expr string, t *typ) string {
+	switch t.kind {
+	case kindString:
+		return expr + ` != ""`
+	case kindBytes, kindByteArray, kindSlice:
+		return "len(" + expr + ") != 0"
+	case kindInt, kindUint:
+		return expr + " != 0"
+	case kindBool:
+		return expr
+	case kindPointer:
+		return expr + " != nil"
+	case kindBelement:
+		// a zero Belement is invalid, which present already leaves out
+		return ""
+	case kindStruct:
+		g.imports["reflect"] = true
+		return "!reflect.ValueOf(" + expr + ").IsZero()"
+	}
+	if strings.HasPrefix(t.notEmpty, "!reflect.") {
+		g.imports["reflect"] = true
+	}
+	return fmt.Sprintf(t.notEmpty, expr)
+}
+
+func (g *generator) encoder(s structType) {
+	g.printf("\nfunc (x *%s) MarshalBencode() ([]byte, error) {\n\treturn x.AppendBencode(nil)\n}\n", s.name)
+	g.printf("\nfunc (x *%s) AppendBencode(dst []byte) ([]byte, error) {\n", s.name)
+
+	// the body is written aside to find out whether it declares err
+	out := g.buf
+	g.buf = new(bytes.Buffer)
+	g.usesErr = false
+	defer func() {
+		if g.usesErr {
+			out.WriteString("\tvar err error\n")
+		}
+		out.Write(g.buf.Bytes())
+		g.buf = out
+	}()
+
+	if s.extra != nil {
+		g.imports["maps"] = true
+		g.imports["slices"] = true
+		g.imports["fmt"] = true
+		g.printf("\tkeys := slices.Sorted(maps.Keys(%s))\n", s.extra.expr)
+		if len(s.fields) != 0 {
+			g.printf("\tfor _, k := range keys {\n\t\tswitch k {\n\t\tcase ")
+			for i, f := range s.fields {
+				if i != 0 {
+					g.printf(", ")
+				}
+				g.printf("%q", f.key)
+			}
+			g.printf(":\n\t\t\treturn nil, fmt.Errorf(\"bencode: %s: extra key %%q collides with field\", k)\n\t\t}\n\t}\n", s.name)
+		}
+	}
+
+	g.printf("\tdst = append(dst, 'd')\n")
+	for _, f := range s.fields {
+		if s.extra != nil {
+			// extra keys are merged in so the dict stays in key order
+			g.printf("\tfor ; len(keys) != 0 && keys[0] < %q; keys = keys[1:] {\n", f.key)
+			g.encodeExtra(s.extra.expr, "keys[0]")
+			g.printf("\t}\n")
+		}
+
+		var conds []string
+		if c := present(f.expr, f.typ); c != "" {
+			conds = append(conds, c)
+		}
+		if c := ""; f.omitEmpty {
+			if c = g.notEmpty(f.expr, f.typ); c != "" && !slices.Contains(conds, c) {
+				conds = append(conds, c)
+			}
+		}
+		if len(conds) != 0 {
+			g.printf("\tif %s {\n", strings.Join(conds, " && "))
+		}
+		g.printf("\tdst = append(dst, %q...)\n", bencode.AppendString(nil, f.key))
+		g.encodeValue(f.expr, f.typ, f.name)
+		if len(conds) != 0 {
+			g.printf("\t}\n")
+		}
+	}
+	if s.extra != nil {
+		g.printf("\tfor _, k := range keys {\n")
+		g.encodeExtra(s.extra.expr, "k")
+		g.printf("\t}\n")
+	}
+	g.printf("\treturn append(dst, 'e'), nil\n}\n")
+}
+
+func (g *generator) encodeExtra(extra string, key string) {
+	g.usesErr = true
+	g.printf("\t\tif e := %s[%s]; e.Type != bencode.TypeInvalid {\n", extra, key)
+	g.printf("\t\t\tdst = bencode.AppendString(dst, %s)\n", key)
+	g.printf("\t\t\tif dst, err = bencode.AppendValue(dst, e); err != nil {\n\t\t\t\treturn nil, err\n\t\t\t}\n\t\t}\n")
+}
+
+// encodeValue writes the statements appending expr, which is known to be
+// present.
+func (g *generator) encodeValue(expr string, t *typ, name string) {
+	switch t.kind {
+	case kindString:
+		g.printf("\tdst = bencode.AppendString(dst, %s)\n", convert("string", expr, t))
+	case kindBytes:
+		g.printf("\tdst = bencode.AppendBytes(dst, %s)\n", expr)
+	case kindByteArray:
+		g.printf("\tdst = bencode.AppendBytes(dst, %s[:])\n", paren(expr))
+	case kindInt:
+		g.printf("\tdst = bencode.AppendInt(dst, %s)\n", convert("int64", expr, t))
+	case kindUint:
+		if bits(t.basic) > 16 {
+			g.imports["fmt"] = true
+			g.imports["math"] = true
+			g.printf("\tif %s > math.MaxInt {\n", convert("uint64", expr, t))
+			g.printf("\t\treturn nil, fmt.Errorf(\"bencode: %s: value %%d overflows int\", %s)\n\t}\n", name, expr)
+		}
+		g.printf("\tdst = bencode.AppendInt(dst, int64(%s))\n", expr)
+	case kindBool:
+		g.printf("\tif %s {\n\t\tdst = bencode.AppendInt(dst, 1)\n\t} else {\n\t\tdst = bencode.AppendInt(dst, 0)\n\t}\n", expr)
+	case kindBelement:
+		g.usesErr = true
+		g.printf("\tif dst, err = bencode.AppendValue(dst, %s); err != nil {\n\t\treturn nil, err\n\t}\n", expr)
+	case kindStruct:
+		g.usesErr = true
+		g.printf("\tif dst, err = %s.AppendBencode(dst); err != nil {\n\t\treturn nil, err\n\t}\n", strings.TrimPrefix(expr, "*"))
+	case kindPointer:
+		g.encodeValue("*"+expr, t.elem, name)
+	case kindSlice:
+		i := g.newVar("i")
+		elem := paren(expr) + "[" + i + "]"
+		g.printf("\tdst = append(dst, 'l')\n\tfor %s := range %s {\n", i, expr)
+		if c := present(elem, t.elem); c != "" {
+			g.printf("\tif %s {\n", c)
+			g.encodeValue(elem, t.elem, name)
+			g.printf("\t}\n")
+		} else {
+			g.encodeValue(elem, t.elem, name)
+		}
+		g.printf("\t}\n\tdst = append(dst, 'e')\n")
+	default:
+		g.usesErr = true
+		g.printf("\tif dst, err = bencode.AppendValue(dst, &%s); err != nil {\n\t\treturn nil, err\n\t}\n", expr)
+	}
+}
+
+func (g *generator) decoder(s structType) {
+	g.printf("\nfunc (x *%s) UnmarshalBencode(data []byte) error {\n", s.name)
+	g.printf("\treturn x.scanBencode(bencode.NewScanner(data))\n}\n")
+
+	g.printf("\nfunc (x *%s) scanBencode(s *bencode.Scanner) error {\n", s.name)
+	var checked []int
+	for i, f := range s.fields {
+		if f.required || f.def != "" {
+			checked = append(checked, i)
+		}
+	}
+	if len(checked) != 0 {
+		g.printf("\tvar found [%d]bool\n", len(s.fields))
+	}
+	if s.extra != nil {
+		g.printf("\t%s = nil\n", s.extra.expr)
+	}
+	g.printf("\tif err := s.Dict(); err != nil {\n\t\treturn err\n\t}\n")
+	g.printf("\tfor s.More() {\n\t\tkey, err := s.Bytes()\n\t\tif err != nil {\n\t\t\treturn err\n\t\t}\n\t\tswitch string(key) {\n")
+	for i, f := range s.fields {
+		g.printf("\tcase %q:\n", f.key)
+		g.decodeValue(f.expr, f.typ, f.name)
+		if len(checked) != 0 {
+			g.printf("\tfound[%d] = true\n", i)
+		}
+	}
+	g.printf("\tdefault:\n")
+	if s.extra != nil {
+		g.printf("\t\traw, err := s.Raw()\n\t\tif err != nil {\n\t\t\treturn err\n\t\t}\n")
+		g.printf("\t\te, err := bencode.Decode(raw)\n\t\tif err != nil {\n\t\t\treturn err\n\t\t}\n")
+		g.printf("\t\tif %[1]s == nil {\n\t\t\t%[1]s = make(map[string]bencode.Belement)\n\t\t}\n\t\t%[1]s[string(key)] = e\n", s.extra.expr)
+	} else {
+		g.printf("\t\tif err := s.Skip(); err != nil {\n\t\t\treturn err\n\t\t}\n")
+	}
+	g.printf("\t}\n\t}\n\tif err := s.End(); err != nil {\n\t\treturn err\n\t}\n")
+
+	for _, i := range checked {
+		f := s.fields[i]
+		g.printf("\tif !found[%d] {\n", i)
+		if f.required {
+			g.imports["fmt"] = true
+			g.printf("\t\treturn fmt.Errorf(\"bencode: %s: missing required key %%q\", %q)\n", s.name, f.key)
+		} else {
+			g.printf("\t\t%s = %s\n", f.expr, f.def)
+		}
+		g.printf("\t}\n")
+	}
+	g.printf("\treturn nil\n}\n")
+}
+
+// decodeValue writes the statements scanning the next element into target.
+func (g *generator) decodeValue(target string, t *typ, name string) {
+	switch t.kind {
+	case kindString, kindBytes, kindByteArray:
+		b := g.newVar("b")
+		g.printf("\t%s, err := s.Bytes()\n\tif err != nil {\n\t\treturn err\n\t}\n", b)
+		switch t.kind {
+		case kindString:
+			g.printf("\t%s = %s(%s)\n", target, t.name, b)
+		case kindBytes:
+			g.imports["bytes"] = true
+			g.printf("\t%s = bytes.Clone(%s)\n", target, b)
+		default:
+			g.imports["fmt"] = true
+			g.printf("\tif len(%s) != len(%s) {\n", b, target)
+			g.printf("\t\treturn fmt.Errorf(\"bencode: %s: string of length %%d does not fit %s\", len(%s))\n\t}\n", name, t.name, b)
+			g.printf("\tcopy(%s[:], %s)\n", paren(target), b)
+		}
+	case kindInt, kindUint, kindBool:
+		n := g.newVar("n")
+		g.printf("\t%s, err := s.Int()\n\tif err != nil {\n\t\treturn err\n\t}\n", n)
+		switch {
+		case t.kind == kindBool:
+			g.printf("\t%s = %s != 0\n", target, n)
+			return
+		case t.kind == kindUint && (t.basic == "uint" || t.basic == "uint64" || t.basic == "uintptr"):
+			g.printf("\tif %s < 0 {\n", n)
+		case t.kind == kindUint:
+			g.printf("\tif %[1]s < 0 || int(%[2]s(%[1]s)) != %[1]s {\n", n, t.basic)
+		case t.basic != "int" && t.basic != "int64":
+			g.printf("\tif int(%[2]s(%[1]s)) != %[1]s {\n", n, t.basic)
+		default:
+			g.printf("\t%s = %s\n", target, convert(t.name, n, &typ{name: "int"}))
+			return
+		}
+		g.imports["fmt"] = true
+		g.printf("\t\treturn fmt.Errorf(\"bencode: %s: value %%d overflows %s\", %s)\n\t}\n", name, t.name, n)
+		g.printf("\t%s = %s(%s)\n", target, t.name, n)
+	case kindBelement:
+		raw := g.newVar("raw")
+		g.printf("\t%s, err := s.Raw()\n\tif err != nil {\n\t\treturn err\n\t}\n", raw)
+		g.printf("\tif %s, err = bencode.Decode(%s); err != nil {\n\t\treturn err\n\t}\n", target, raw)
+	case kindStruct:
+		g.printf("\tif err := %s.scanBencode(s); err != nil {\n\t\treturn err\n\t}\n", strings.TrimPrefix(target, "*"))
+	case kindPointer:
+		g.printf("\tif %s == nil {\n\t\t%s = new(%s)\n\t}\n", target, target, t.elem.name)
+		g.decodeValue("*"+target, t.elem, name)
+	case kindSlice:
+		e := g.newVar("e")
+		g.printf("\tif err := s.List(); err != nil {\n\t\treturn err\n\t}\n")
+		g.printf("\t%s = %s{}\n\tfor s.More() {\n\t\tvar %s %s\n", target, t.name, e, t.elem.name)
+		g.decodeValue(e, t.elem, name)
+		g.printf("\t%s = append(%s, %s)\n\t}\n", target, target, e)
+		g.printf("\tif err := s.End(); err != nil {\n\t\treturn err\n\t}\n")
+	default:
+		raw := g.newVar("raw")
+		g.printf("\t%s, err := s.Raw()\n\tif err != nil {\n\t\treturn err\n\t}\n", raw)
+		g.printf("\tif err := bencode.Unmarshal(%s, &%s); err != nil {\n\t\treturn err\n\t}\n", raw, target)
+	}
+}
@@ -0,0 +1,661 @@
+// Code generated by bencodegen; DO NOT EDIT.
+
+package example
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/deathcrafter/bencode"
+)
+
+func (x *Announce) MarshalBencode() ([]byte, error) {
+	return x.AppendBencode(nil)
+}
+
+func (x *Announce) AppendBencode(dst []byte) ([]byte, error) {
+	var err error
+	keys := slices.Sorted(maps.Keys(x.Extra))
+	for _, k := range keys {
+		switch k {
+		case "failure reason", "flags", "hashes", "incomplete", "info", "interval", "min interval", "peers", "private", "raw", "trackers":
+			return nil, fmt.Errorf("bencode: Announce: extra key %q collides with field", k)
+		}
+	}
+	dst = append(dst, 'd')
+	for ; len(keys) != 0 && keys[0] < "failure reason"; keys = keys[1:] {
+		if e := x.Extra[keys[0]]; e.Type != bencode.TypeInvalid {
+			dst = bencode.AppendString(dst, keys[0])
+			if dst, err = bencode.AppendValue(dst, e); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if x.FailureReason != "" {
+		dst = append(dst, "14:failure reason"...)
+		dst = bencode.AppendString(dst, x.FailureReason)
+	}
+	for ; len(keys) != 0 && keys[0] < "flags"; keys = keys[1:] {
+		if e := x.Extra[keys[0]]; e.Type != bencode.TypeInvalid {
+			dst = bencode.AppendString(dst, keys[0])
+			if dst, err = bencode.AppendValue(dst, e); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if x.Flags != 0 {
+		dst = append(dst, "5:flags"...)
+		dst = bencode.AppendInt(dst, int64(x.Flags))
+	}
+	for ; len(keys) != 0 && keys[0] < "hashes"; keys = keys[1:] {
+		if e := x.Extra[keys[0]]; e.Type != bencode.TypeInvalid {
+			dst = bencode.AppendString(dst, keys[0])
+			if dst, err = bencode.AppendValue(dst, e); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(x.Hashes) != 0 {
+		dst = append(dst, "6:hashes"...)
+		dst = append(dst, 'l')
+		for i1 := range x.Hashes {
+			dst = bencode.AppendBytes(dst, x.Hashes[i1][:])
+		}
+		dst = append(dst, 'e')
+	}
+	for ; len(keys) != 0 && keys[0] < "incomplete"; keys = keys[1:] {
+		if e := x.Extra[keys[0]]; e.Type != bencode.TypeInvalid {
+			dst = bencode.AppendString(dst, keys[0])
+			if dst, err = bencode.AppendValue(dst, e); err != nil {
+				return nil, err
+			}
+		}
+	}
+	dst = append(dst, "10:incomplete"...)
+	dst = bencode.AppendInt(dst, int64(x.Incomplete))
+	for ; len(keys) != 0 && keys[0] < "info"; keys = keys[1:] {
+		if e := x.Extra[keys[0]]; e.Type != bencode.TypeInvalid {
+			dst = bencode.AppendString(dst, keys[0])
+			if dst, err = bencode.AppendValue(dst, e); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if x.Info != nil {
+		dst = append(dst, "4:info"...)
+		if dst, err = x.Info.AppendBencode(dst); err != nil {
+			return nil, err
+		}
+	}
+	for ; len(keys) != 0 && keys[0] < "interval"; keys = keys[1:] {
+		if e := x.Extra[keys[0]]; e.Type != bencode.TypeInvalid {
+			dst = bencode.AppendString(dst, keys[0])
+			if dst, err = bencode.AppendValue(dst, e); err != nil {
+				return nil, err
+			}
+		}
+	}
+	dst = append(dst, "8:interval"...)
+	dst = bencode.AppendInt(dst, int64(x.Interval))
+	for ; len(keys) != 0 && keys[0] < "min interval"; keys = keys[1:] {
+		if e := x.Extra[keys[0]]; e.Type != bencode.TypeInvalid {
+			dst = bencode.AppendString(dst, keys[0])
+			if dst, err = bencode.AppendValue(dst, e); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if x.MinInterval != nil {
+		dst = append(dst, "12:min interval"...)
+		dst = bencode.AppendInt(dst, int64(*x.MinInterval))
+	}
+	for ; len(keys) != 0 && keys[0] < "peers"; keys = keys[1:] {
+		if e := x.Extra[keys[0]]; e.Type != bencode.TypeInvalid {
+			dst = bencode.AppendString(dst, keys[0])
+			if dst, err = bencode.AppendValue(dst, e); err != nil {
+				return nil, err
+			}
+		}
+	}
+	dst = append(dst, "5:peers"...)
+	dst = append(dst, 'l')
+	for i2 := range x.Peers {
+		if dst, err = x.Peers[i2].AppendBencode(dst); err != nil {
+			return nil, err
+		}
+	}
+	dst = append(dst, 'e')
+	for ; len(keys) != 0 && keys[0] < "private"; keys = keys[1:] {
+		if e := x.Extra[keys[0]]; e.Type != bencode.TypeInvalid {
+			dst = bencode.AppendString(dst, keys[0])
+			if dst, err = bencode.AppendValue(dst, e); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if x.Private {
+		dst = append(dst, "7:private"...)
+		if x.Private {
+			dst = bencode.AppendInt(dst, 1)
+		} else {
+			dst = bencode.AppendInt(dst, 0)
+		}
+	}
+	for ; len(keys) != 0 && keys[0] < "raw"; keys = keys[1:] {
+		if e := x.Extra[keys[0]]; e.Type != bencode.TypeInvalid {
+			dst = bencode.AppendString(dst, keys[0])
+			if dst, err = bencode.AppendValue(dst, e); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if x.Raw.Type != bencode.TypeInvalid {
+		dst = append(dst, "3:raw"...)
+		if dst, err = bencode.AppendValue(dst, x.Raw); err != nil {
+			return nil, err
+		}
+	}
+	for ; len(keys) != 0 && keys[0] < "trackers"; keys = keys[1:] {
+		if e := x.Extra[keys[0]]; e.Type != bencode.TypeInvalid {
+			dst = bencode.AppendString(dst, keys[0])
+			if dst, err = bencode.AppendValue(dst, e); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(x.Trackers) != 0 {
+		dst = append(dst, "8:trackers"...)
+		if dst, err = bencode.AppendValue(dst, &x.Trackers); err != nil {
+			return nil, err
+		}
+	}
+	for _, k := range keys {
+		if e := x.Extra[k]; e.Type != bencode.TypeInvalid {
+			dst = bencode.AppendString(dst, k)
+			if dst, err = bencode.AppendValue(dst, e); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return append(dst, 'e'), nil
+}
+
+func (x *Announce) UnmarshalBencode(data []byte) error {
+	return x.scanBencode(bencode.NewScanner(data))
+}
+
+func (x *Announce) scanBencode(s *bencode.Scanner) error {
+	var found [11]bool
+	x.Extra = nil
+	if err := s.Dict(); err != nil {
+		return err
+	}
+	for s.More() {
+		key, err := s.Bytes()
+		if err != nil {
+			return err
+		}
+		switch string(key) {
+		case "failure reason":
+			b3, err := s.Bytes()
+			if err != nil {
+				return err
+			}
+			x.FailureReason = string(b3)
+			found[0] = true
+		case "flags":
+			n4, err := s.Int()
+			if err != nil {
+				return err
+			}
+			if n4 < 0 || int(uint8(n4)) != n4 {
+				return fmt.Errorf("bencode: Announce.Flags: value %d overflows Flags", n4)
+			}
+			x.Flags = Flags(n4)
+			found[1] = true
+		case "hashes":
+			if err := s.List(); err != nil {
+				return err
+			}
+			x.Hashes = []Hash{}
+			for s.More() {
+				var e5 Hash
+				b6, err := s.Bytes()
+				if err != nil {
+					return err
+				}
+				if len(b6) != len(e5) {
+					return fmt.Errorf("bencode: Announce.Hashes: string of length %d does not fit Hash", len(b6))
+				}
+				copy(e5[:], b6)
+				x.Hashes = append(x.Hashes, e5)
+			}
+			if err := s.End(); err != nil {
+				return err
+			}
+			found[2] = true
+		case "incomplete":
+			n7, err := s.Int()
+			if err != nil {
+				return err
+			}
+			if int(int32(n7)) != n7 {
+				return fmt.Errorf("bencode: Announce.Incomplete: value %d overflows int32", n7)
+			}
+			x.Incomplete = int32(n7)
+			found[3] = true
+		case "info":
+			if x.Info == nil {
+				x.Info = new(Info)
+			}
+			if err := x.Info.scanBencode(s); err != nil {
+				return err
+			}
+			found[4] = true
+		case "interval":
+			n8, err := s.Int()
+			if err != nil {
+				return err
+			}
+			x.Interval = n8
+			found[5] = true
+		case "min interval":
+			if x.MinInterval == nil {
+				x.MinInterval = new(int)
+			}
+			n9, err := s.Int()
+			if err != nil {
+				return err
+			}
+			*x.MinInterval = n9
+			found[6] = true
+		case "peers":
+			if err := s.List(); err != nil {
+				return err
+			}
+			x.Peers = []Peer{}
+			for s.More() {
+				var e10 Peer
+				if err := e10.scanBencode(s); err != nil {
+					return err
+				}
+				x.Peers = append(x.Peers, e10)
+			}
+			if err := s.End(); err != nil {
+				return err
+			}
+			found[7] = true
+		case "private":
+			n11, err := s.Int()
+			if err != nil {
+				return err
+			}
+			x.Private = n11 != 0
+			found[8] = true
+		case "raw":
+			raw12, err := s.Raw()
+			if err != nil {
+				return err
+			}
+			if x.Raw, err = bencode.Decode(raw12); err != nil {
+				return err
+			}
+			found[9] = true
+		case "trackers":
+			raw13, err := s.Raw()
+			if err != nil {
+				return err
+			}
+			if err := bencode.Unmarshal(raw13, &x.Trackers); err != nil {
+				return err
+			}
+			found[10] = true
+		default:
+			raw, err := s.Raw()
+			if err != nil {
+				return err
+			}
+			e, err := bencode.Decode(raw)
+			if err != nil {
+				return err
+			}
+			if x.Extra == nil {
+				x.Extra = make(map[string]bencode.Belement)
+			}
+			x.Extra[string(key)] = e
+		}
+	}
+	if err := s.End(); err != nil {
+		return err
+	}
+	if !found[3] {
+		x.Incomplete = -1
+	}
+	if !found[5] {
+		return fmt.Errorf("bencode: Announce: missing required key %q", "interval")
+	}
+	return nil
+}
+
+func (x *Peer) MarshalBencode() ([]byte, error) {
+	return x.AppendBencode(nil)
+}
+
+func (x *Peer) AppendBencode(dst []byte) ([]byte, error) {
+	dst = append(dst, 'd')
+	dst = append(dst, "2:ip"...)
+	dst = bencode.AppendString(dst, x.IP)
+	if len(x.ID) != 0 {
+		dst = append(dst, "7:peer id"...)
+		dst = bencode.AppendBytes(dst, x.ID)
+	}
+	dst = append(dst, "4:port"...)
+	dst = bencode.AppendInt(dst, int64(x.Port))
+	return append(dst, 'e'), nil
+}
+
+func (x *Peer) UnmarshalBencode(data []byte) error {
+	return x.scanBencode(bencode.NewScanner(data))
+}
+
+func (x *Peer) scanBencode(s *bencode.Scanner) error {
+	if err := s.Dict(); err != nil {
+		return err
+	}
+	for s.More() {
+		key, err := s.Bytes()
+		if err != nil {
+			return err
+		}
+		switch string(key) {
+		case "ip":
+			b14, err := s.Bytes()
+			if err != nil {
+				return err
+			}
+			x.IP = string(b14)
+		case "peer id":
+			b15, err := s.Bytes()
+			if err != nil {
+				return err
+			}
+			x.ID = bytes.Clone(b15)
+		case "port":
+			n16, err := s.Int()
+			if err != nil {
+				return err
+			}
+			if n16 < 0 || int(uint16(n16)) != n16 {
+				return fmt.Errorf("bencode: Peer.Port: value %d overflows uint16", n16)
+			}
+			x.Port = uint16(n16)
+		default:
+			if err := s.Skip(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := s.End(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (x *Info) MarshalBencode() ([]byte, error) {
+	return x.AppendBencode(nil)
+}
+
+func (x *Info) AppendBencode(dst []byte) ([]byte, error) {
+	var err error
+	dst = append(dst, 'd')
+	if len(x.Files) != 0 {
+		dst = append(dst, "5:files"...)
+		dst = append(dst, 'l')
+		for i17 := range x.Files {
+			dst = append(dst, 'l')
+			for i18 := range x.Files[i17] {
+				dst = bencode.AppendString(dst, x.Files[i17][i18])
+			}
+			dst = append(dst, 'e')
+		}
+		dst = append(dst, 'e')
+	}
+	dst = append(dst, "6:length"...)
+	dst = bencode.AppendInt(dst, x.Length)
+	dst = append(dst, "4:name"...)
+	dst = bencode.AppendString(dst, x.common.Name)
+	if x.Note != nil {
+		dst = append(dst, "4:note"...)
+		if dst, err = bencode.AppendValue(dst, &x.Note); err != nil {
+			return nil, err
+		}
+	}
+	return append(dst, 'e'), nil
+}
+
+func (x *Info) UnmarshalBencode(data []byte) error {
+	return x.scanBencode(bencode.NewScanner(data))
+}
+
+func (x *Info) scanBencode(s *bencode.Scanner) error {
+	var found [4]bool
+	if err := s.Dict(); err != nil {
+		return err
+	}
+	for s.More() {
+		key, err := s.Bytes()
+		if err != nil {
+			return err
+		}
+		switch string(key) {
+		case "files":
+			if err := s.List(); err != nil {
+				return err
+			}
+			x.Files = [][]string{}
+			for s.More() {
+				var e19 []string
+				if err := s.List(); err != nil {
+					return err
+				}
+				e19 = []string{}
+				for s.More() {
+					var e20 string
+					b21, err := s.Bytes()
+					if err != nil {
+						return err
+					}
+					e20 = string(b21)
+					e19 = append(e19, e20)
+				}
+				if err := s.End(); err != nil {
+					return err
+				}
+				x.Files = append(x.Files, e19)
+			}
+			if err := s.End(); err != nil {
+				return err
+			}
+			found[0] = true
+		case "length":
+			n22, err := s.Int()
+			if err != nil {
+				return err
+			}
+			x.Length = int64(n22)
+			found[1] = true
+		case "name":
+			b23, err := s.Bytes()
+			if err != nil {
+				return err
+			}
+			x.common.Name = string(b23)
+			found[2] = true
+		case "note":
+			raw24, err := s.Raw()
+			if err != nil {
+				return err
+			}
+			if err := bencode.Unmarshal(raw24, &x.Note); err != nil {
+				return err
+			}
+			found[3] = true
+		default:
+			if err := s.Skip(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := s.End(); err != nil {
+		return err
+	}
+	if !found[2] {
+		return fmt.Errorf("bencode: Info: missing required key %q", "name")
+	}
+	return nil
+}
+
+func (x *Query) MarshalBencode() ([]byte, error) {
+	return x.AppendBencode(nil)
+}
+
+func (x *Query) AppendBencode(dst []byte) ([]byte, error) {
+	var err error
+	dst = append(dst, 'd')
+	if len(x.Children) != 0 {
+		dst = append(dst, "8:children"...)
+		dst = append(dst, 'l')
+		for i25 := range x.Children {
+			if x.Children[i25] != nil {
+				if dst, err = x.Children[i25].AppendBencode(dst); err != nil {
+					return nil, err
+				}
+			}
+		}
+		dst = append(dst, 'e')
+	}
+	dst = append(dst, "2:id"...)
+	dst = bencode.AppendBytes(dst, x.ID[:])
+	dst = append(dst, "12:implied_port"...)
+	if x.Implied {
+		dst = bencode.AppendInt(dst, 1)
+	} else {
+		dst = bencode.AppendInt(dst, 0)
+	}
+	if x.Seq != nil {
+		dst = append(dst, "3:seq"...)
+		dst = bencode.AppendInt(dst, *x.Seq)
+	}
+	if x.Target != nil {
+		dst = append(dst, "6:target"...)
+		dst = bencode.AppendBytes(dst, (*x.Target)[:])
+	}
+	if len(x.Want) != 0 {
+		dst = append(dst, "4:want"...)
+		dst = append(dst, 'l')
+		for i26 := range x.Want {
+			dst = bencode.AppendString(dst, x.Want[i26])
+		}
+		dst = append(dst, 'e')
+	}
+	return append(dst, 'e'), nil
+}
+
+func (x *Query) UnmarshalBencode(data []byte) error {
+	return x.scanBencode(bencode.NewScanner(data))
+}
+
+func (x *Query) scanBencode(s *bencode.Scanner) error {
+	if err := s.Dict(); err != nil {
+		return err
+	}
+	for s.More() {
+		key, err := s.Bytes()
+		if err != nil {
+			return err
+		}
+		switch string(key) {
+		case "children":
+			if err := s.List(); err != nil {
+				return err
+			}
+			x.Children = []*Query{}
+			for s.More() {
+				var e27 *Query
+				if e27 == nil {
+					e27 = new(Query)
+				}
+				if err := e27.scanBencode(s); err != nil {
+					return err
+				}
+				x.Children = append(x.Children, e27)
+			}
+			if err := s.End(); err != nil {
+				return err
+			}
+		case "id":
+			b28, err := s.Bytes()
+			if err != nil {
+				return err
+			}
+			if len(b28) != len(x.ID) {
+				return fmt.Errorf("bencode: Query.ID: string of length %d does not fit Hash", len(b28))
+			}
+			copy(x.ID[:], b28)
+		case "implied_port":
+			n29, err := s.Int()
+			if err != nil {
+				return err
+			}
+			x.Implied = n29 != 0
+		case "seq":
+			if x.Seq == nil {
+				x.Seq = new(int64)
+			}
+			n30, err := s.Int()
+			if err != nil {
+				return err
+			}
+			*x.Seq = int64(n30)
+		case "target":
+			if x.Target == nil {
+				x.Target = new(Hash)
+			}
+			b31, err := s.Bytes()
+			if err != nil {
+				return err
+			}
+			if len(b31) != len(*x.Target) {
+				return fmt.Errorf("bencode: Query.Target: string of length %d does not fit Hash", len(b31))
+			}
+			copy((*x.Target)[:], b31)
+		case "want":
+			if err := s.List(); err != nil {
+				return err
+			}
+			x.Want = []string{}
+			for s.More() {
+				var e32 string
+				b33, err := s.Bytes()
+				if err != nil {
+					return err
+				}
+				e32 = string(b33)
+				x.Want = append(x.Want, e32)
+			}
+			if err := s.End(); err != nil {
+				return err
+			}
+		default:
+			if err := s.Skip(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := s.End(); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+// Package example holds tracker and DHT style messages to exercise the
+// code written by bencodegen.
+package example
+
+import (
+	"github.com/deathcrafter/bencode"
+)
+
+//go:generate go run ../.. -type Announce,Peer,Info,Query
+
+type Flags uint8
+
+type Hash [20]byte
+
+type Peer struct {
+	ID   []byte `bencode:"peer id,omitempty"`
+	IP   string `bencode:"ip"`
+	Port uint16 `bencode:"port"`
+}
+
+type Announce struct {
+	FailureReason string                      `bencode:"failure reason,omitempty"`
+	Interval      int                         `bencode:"interval,required"`
+	MinInterval   *int                        `bencode:"min interval"`
+	Incomplete    int32                       `bencode:"incomplete,default=-1"`
+	Peers         []Peer                      `bencode:"peers"`
+	Hashes        []Hash                      `bencode:"hashes,omitempty"`
+	Private       bool                        `bencode:"private,omitempty"`
+	Info          *Info                       `bencode:"info,omitempty"`
+	Flags         Flags                       `bencode:"flags,omitempty"`
+	Raw           bencode.Belement            `bencode:"raw"`
+	Trackers      map[string][]string         `bencode:"trackers,omitempty"`
+	Cache         string                      `bencode:"-"`
+	Extra         map[string]bencode.Belement `bencode:",extra"`
+}
+
+type common struct {
+	Name string `bencode:"name,required"`
+}
+
+type Info struct {
+	common
+	Length int64       `bencode:"length"`
+	Files  [][]string  `bencode:"files,omitempty"`
+	Note   interface{} `bencode:"note,omitempty"`
+}
+
+type Query struct {
+	ID       Hash     `bencode:"id"`
+	Target   *Hash    `bencode:"target"`
+	Want     []string `bencode:"want,omitempty"`
+	Seq      *int64   `bencode:"seq"`
+	Implied  bool     `bencode:"implied_port"`
+	Children []*Query `bencode:"children,omitempty"`
+}
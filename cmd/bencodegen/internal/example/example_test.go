@@ -0,0 +1,146 @@
+package example
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/deathcrafter/bencode"
+)
+
+func testAnnounce() Announce {
+	minInterval := 60
+	return Announce{
+		Interval:    1800,
+		MinInterval: &minInterval,
+		Incomplete:  -1,
+		Peers: []Peer{
+			{ID: []byte("-qB4500-abcdefghijkl"), IP: "10.0.0.1", Port: 6881},
+			{IP: "10.0.0.2", Port: 51413},
+		},
+		Hashes: []Hash{{1, 2, 3}},
+		Info: &Info{
+			common: common{Name: "a.txt"},
+			Length: 12,
+			Files:  [][]string{{"dir", "a.txt"}},
+			Note:   "hi",
+		},
+		Flags:    3,
+		Raw:      bencode.Belement{Type: bencode.TypeList, Value: []bencode.Belement{{Type: bencode.TypeInt, Value: 1}}},
+		Trackers: map[string][]string{"udp": {"udp://t:1"}},
+		Extra: map[string]bencode.Belement{
+			"aaa": {Type: bencode.TypeInt, Value: 1},
+			"zzz": {Type: bencode.TypeString, Value: "z"},
+		},
+	}
+}
+
+func TestGeneratedMatchesReflection(t *testing.T) {
+	v := testAnnounce()
+	generated, err := v.MarshalBencode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a copy cannot use the pointer methods, so this takes the reflective path
+	reflective, err := bencode.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(generated) != string(reflective) {
+		t.Fatalf("Generated %q, reflection %q", generated, reflective)
+	}
+	if err := bencode.CheckCanonical(generated); err != nil {
+		t.Fatal(err)
+	}
+
+	x := Announce{Cache: "kept"}
+	if err := x.UnmarshalBencode(generated); err != nil {
+		t.Fatal(err)
+	}
+	v.Cache = "kept"
+	if !reflect.DeepEqual(x, v) {
+		t.Fatalf("Expected %+v, got %+v", v, x)
+	}
+}
+
+func TestGeneratedRecursive(t *testing.T) {
+	seq := int64(7)
+	v := Query{
+		ID:       Hash{1},
+		Target:   &Hash{2},
+		Seq:      &seq,
+		Children: []*Query{{ID: Hash{3}, Want: []string{"n4"}}, nil},
+	}
+	e, err := v.MarshalBencode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reflective, _ := bencode.Marshal(v); string(e) != string(reflective) {
+		t.Fatalf("Generated %q, reflection %q", e, reflective)
+	}
+
+	x := Query{}
+	if err := bencode.Unmarshal(e, &x); err != nil {
+		t.Fatal(err)
+	}
+	v.Children = v.Children[:1]
+	v.Children[0].Want = []string{"n4"}
+	if !reflect.DeepEqual(x, v) {
+		t.Fatalf("Expected %+v, got %+v", v, x)
+	}
+}
+
+func TestGeneratedErrors(t *testing.T) {
+	cases := map[string]string{
+		"d5:peerslee":                  `bencode: Announce: missing required key "interval"`,
+		"d8:intervali1e5:flagsi256ee":  "bencode: Announce.Flags: value 256 overflows Flags",
+		"d8:intervali1e6:hashesl1:aee": "bencode: Announce.Hashes: string of length 1 does not fit Hash",
+		"d8:intervali1e4:infodee":      `bencode: Info: missing required key "name"`,
+		"d8:intervali0x1ee":            `Invalid integer format: strconv.Atoi: parsing "0x1": invalid syntax at offset 12`,
+	}
+	for data, expected := range cases {
+		x := Announce{}
+		if err := x.UnmarshalBencode([]byte(data)); err == nil || err.Error() != expected {
+			t.Fatalf("%q: expected %q, got %v", data, expected, err)
+		}
+	}
+
+	// what bencode.Unmarshal accepts is accepted too
+	x := Announce{}
+	if err := x.UnmarshalBencode([]byte("d8:intervali01ee\n")); err != nil || x.Interval != 1 {
+		t.Fatalf("Expected interval 1, got %d, %v", x.Interval, err)
+	}
+
+	v := Announce{Interval: 1, Extra: map[string]bencode.Belement{"peers": {Type: bencode.TypeInt, Value: 1}}}
+	if _, err := v.MarshalBencode(); err == nil {
+		t.Fatal("Expected collision error, got nil")
+	}
+}
+
+func BenchmarkGeneratedMarshal(b *testing.B) {
+	v := testAnnounce()
+	v.Extra = nil
+	var dst []byte
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var err error
+		if dst, err = v.AppendBencode(dst[:0]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGeneratedUnmarshal(b *testing.B) {
+	v := testAnnounce()
+	v.Extra = nil
+	data, err := v.MarshalBencode()
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		x := Announce{}
+		if err := x.UnmarshalBencode(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
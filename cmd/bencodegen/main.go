@@ -0,0 +1,68 @@
+// Command bencodegen writes AppendBencode, MarshalBencode and
+// UnmarshalBencode methods for struct types, following the `bencode` tags of
+// bencode.Marshal but without reflection.
+//
+// Usage:
+//
+//	bencodegen -type T[,T...] [-output file] [dir]
+//
+// It is meant for go:generate lines such as
+//
+//	//go:generate go run github.com/deathcrafter/bencode/cmd/bencodegen -type Message,Args
+//
+// The package in dir, "." by default, is read from source and the methods are
+// written to <type>_bencode.go there, named after the first type.
+//
+// Fields of type string, []byte, byte arrays, integers, bools,
+// bencode.Belement, the types generated in the same run, and pointers and
+// slices of these are handled by the generated code. Other fields go through
+// bencode.AppendValue and bencode.Unmarshal. Generated decoders accept the
+// same documents as bencode.Unmarshal but do not apply
+// bencode.UnmarshalOptions.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const usage = `usage: bencodegen -type T[,T...] [-output file] [dir]
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stderr))
+}
+
+func run(args []string, stderr io.Writer) int {
+	fs := flag.NewFlagSet("bencodegen", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	types := fs.String("type", "", "")
+	output := fs.String("output", "", "")
+	if err := fs.Parse(args); err != nil || *types == "" || fs.NArg() > 1 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+
+	dir := "."
+	if fs.NArg() == 1 {
+		dir = fs.Arg(0)
+	}
+	names := strings.Split(*types, ",")
+	if *output == "" {
+		*output = filepath.Join(dir, strings.ToLower(names[0])+"_bencode.go")
+	}
+
+	src, err := generate(dir, names, filepath.Base(*output))
+	if err == nil {
+		err = os.WriteFile(*output, src, 0o644)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "bencodegen: %s\n", err)
+		return 1
+	}
+	return 0
+}
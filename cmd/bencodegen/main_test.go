@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExampleIsUpToDate(t *testing.T) {
+	dir := filepath.Join("internal", "example")
+	src, err := generate(dir, []string{"Announce", "Peer", "Info", "Query"}, "announce_bencode.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	committed, err := os.ReadFile(filepath.Join(dir, "announce_bencode.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(src, committed) {
+		t.Fatal("internal/example/announce_bencode.go is stale, run go generate ./...")
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	cases := map[string]string{
+		"type T int":      "type T is not a struct",
+		"type U struct{}": "type T not found",
+		"type T struct{ A int `bencode:\"a,bogus\"` }":                         `unknown option "bogus" for field T.A`,
+		"type T struct {\n\tA int `bencode:\"a\"`\n\tB int `bencode:\"a\"`\n}": `duplicate key "a" in T: fields T.A and T.B`,
+		"type T struct{ A []int `bencode:\",default=1\"` }":                    "invalid default for field T.A",
+		"type T struct{ A map[string]int `bencode:\",extra\"` }":               "extra field T.A must be map[string]bencode.Belement",
+		"type T struct{ A int `bencode:\",inline\"` }":                         "cannot inline field T.A",
+		"type T struct{ *U }\ntype U struct{}":                                 "embedded pointer field T.U needs a key",
+		"type T struct{ A *U `bencode:\",inline\"` }\ntype U struct{}":         "cannot inline pointer field T.A",
+	}
+	for src, expected := range cases {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "t.go"), []byte("package p\n\n"+src+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		var stderr bytes.Buffer
+		if code := run([]string{"-type", "T", dir}, &stderr); code != 1 || !strings.Contains(stderr.String(), expected) {
+			t.Fatalf("%s: expected exit 1 with %q, got %d %q", src, expected, code, stderr.String())
+		}
+	}
+}
+
+func TestUsage(t *testing.T) {
+	var stderr bytes.Buffer
+	if code := run(nil, &stderr); code != 2 || !strings.HasPrefix(stderr.String(), "usage:") {
+		t.Fatalf("Expected usage, got %d %q", code, stderr.String())
+	}
+}
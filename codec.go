@@ -0,0 +1,110 @@
+package bencode
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Marshal and Unmarshal compile a plan for each Go type the first time they
+// see it: an encoderFunc or decoderFunc that handles values of that type
+// without looking at struct tags or choosing a case by kind again.
+
+type encoderFunc func(dst []byte, rv reflect.Value) ([]byte, bool, error)
+
+type decoderFunc func(o UnmarshalOptions, b Belement, rv reflect.Value) error
+
+var (
+	encoderCache sync.Map // map[reflect.Type]encoderFunc
+	decoderCache sync.Map // map[reflect.Type]decoderFunc
+)
+
+func typeEncoder(t reflect.Type) encoderFunc {
+	if f, ok := encoderCache.Load(t); ok {
+		return f.(encoderFunc)
+	}
+
+	// Recursive types reach themselves while their plan is being built, so
+	// store a function that waits for the real one first.
+	var (
+		wg sync.WaitGroup
+		f  encoderFunc
+	)
+	wg.Add(1)
+	fi, loaded := encoderCache.LoadOrStore(t, encoderFunc(func(dst []byte, rv reflect.Value) ([]byte, bool, error) {
+		wg.Wait()
+		return f(dst, rv)
+	}))
+	if loaded {
+		return fi.(encoderFunc)
+	}
+	f = newTypeEncoder(t)
+	wg.Done()
+	encoderCache.Store(t, f)
+	return f
+}
+
+func typeDecoder(t reflect.Type) decoderFunc {
+	if f, ok := decoderCache.Load(t); ok {
+		return f.(decoderFunc)
+	}
+
+	var (
+		wg sync.WaitGroup
+		f  decoderFunc
+	)
+	wg.Add(1)
+	fi, loaded := decoderCache.LoadOrStore(t, decoderFunc(func(o UnmarshalOptions, b Belement, rv reflect.Value) error {
+		wg.Wait()
+		return f(o, b, rv)
+	}))
+	if loaded {
+		return fi.(decoderFunc)
+	}
+	f = newTypeDecoder(t)
+	wg.Done()
+	decoderCache.Store(t, f)
+	return f
+}
+
+// pathError is an error found below the top of a value. Containers add
+// their key or index as it propagates up, innermost first, so a Path is
+// only built for values that fail.
+type pathError struct {
+	rev Path
+	msg string
+}
+
+func newPathError(format string, args ...interface{}) error {
+	return &pathError{msg: fmt.Sprintf(format, args...)}
+}
+
+func (e *pathError) Error() string {
+	p := make(Path, len(e.rev))
+	for i, el := range e.rev {
+		p[len(p)-1-i] = el
+	}
+	return p.String() + ": " + e.msg
+}
+
+func atKey(err error, k string) error {
+	if e, ok := err.(*pathError); ok {
+		e.rev = append(e.rev, PathElement{Key: k})
+	}
+	return err
+}
+
+func atIndex(err error, i int) error {
+	if e, ok := err.(*pathError); ok {
+		e.rev = append(e.rev, PathElement{Index: i, IsIndex: true})
+	}
+	return err
+}
+
+// publicError converts a pathError into the BencodeError callers get.
+func publicError(err error) error {
+	if e, ok := err.(*pathError); ok {
+		return BencodeError{msg: e.Error()}
+	}
+	return err
+}
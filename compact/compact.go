@@ -0,0 +1,96 @@
+// Package compact packs and unpacks the compact peer formats used by
+// trackers, the DHT and peer exchange: 6 bytes per IPv4 peer and 18 bytes
+// per IPv6 peer, address followed by a big-endian port.
+package compact
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+)
+
+const (
+	PeerLen  = 6
+	Peer6Len = 18
+)
+
+func AppendPeer(dst []byte, p netip.AddrPort) ([]byte, error) {
+	addr := p.Addr().Unmap()
+	if !addr.Is4() {
+		return nil, fmt.Errorf("compact: %s is not an IPv4 peer", p)
+	}
+	a := addr.As4()
+	dst = append(dst, a[:]...)
+	return binary.BigEndian.AppendUint16(dst, p.Port()), nil
+}
+
+func AppendPeer6(dst []byte, p netip.AddrPort) ([]byte, error) {
+	addr := p.Addr()
+	if !addr.Is6() || addr.Is4In6() {
+		return nil, fmt.Errorf("compact: %s is not an IPv6 peer", p)
+	}
+	a := addr.As16()
+	dst = append(dst, a[:]...)
+	return binary.BigEndian.AppendUint16(dst, p.Port()), nil
+}
+
+func PackPeers(peers []netip.AddrPort) (string, error) {
+	ret := make([]byte, 0, len(peers)*PeerLen)
+	for _, p := range peers {
+		var err error
+		ret, err = AppendPeer(ret, p)
+		if err != nil {
+			return "", err
+		}
+	}
+	return string(ret), nil
+}
+
+func PackPeers6(peers []netip.AddrPort) (string, error) {
+	ret := make([]byte, 0, len(peers)*Peer6Len)
+	for _, p := range peers {
+		var err error
+		ret, err = AppendPeer6(ret, p)
+		if err != nil {
+			return "", err
+		}
+	}
+	return string(ret), nil
+}
+
+// UnpackPeer accepts a single compact peer of either length.
+func UnpackPeer(data string) (netip.AddrPort, error) {
+	switch len(data) {
+	case PeerLen:
+		addr := netip.AddrFrom4([4]byte([]byte(data[:4])))
+		return netip.AddrPortFrom(addr, binary.BigEndian.Uint16([]byte(data[4:]))), nil
+	case Peer6Len:
+		addr := netip.AddrFrom16([16]byte([]byte(data[:16])))
+		return netip.AddrPortFrom(addr, binary.BigEndian.Uint16([]byte(data[16:]))), nil
+	default:
+		return netip.AddrPort{}, fmt.Errorf("compact: invalid peer length %d", len(data))
+	}
+}
+
+func UnpackPeers(data string) ([]netip.AddrPort, error) {
+	return unpack(data, PeerLen)
+}
+
+func UnpackPeers6(data string) ([]netip.AddrPort, error) {
+	return unpack(data, Peer6Len)
+}
+
+func unpack(data string, size int) ([]netip.AddrPort, error) {
+	if len(data)%size != 0 {
+		return nil, fmt.Errorf("compact: peer list length %d is not a multiple of %d", len(data), size)
+	}
+	ret := make([]netip.AddrPort, 0, len(data)/size)
+	for i := 0; i < len(data); i += size {
+		p, err := UnpackPeer(data[i : i+size])
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, p)
+	}
+	return ret, nil
+}
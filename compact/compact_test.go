@@ -0,0 +1,68 @@
+package compact_test
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/deathcrafter/bencode/compact"
+)
+
+func TestPeersRoundTrip(t *testing.T) {
+	peers := []netip.AddrPort{
+		netip.MustParseAddrPort("10.0.0.1:6881"),
+		netip.MustParseAddrPort("192.168.1.20:51413"),
+	}
+	s, err := compact.PackPeers(peers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s != "\x0a\x00\x00\x01\x1a\xe1\xc0\xa8\x01\x14\xc8\xd5" {
+		t.Fatalf("Unexpected packing %q", s)
+	}
+
+	got, err := compact.UnpackPeers(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != peers[0] || got[1] != peers[1] {
+		t.Fatalf("Expected %v, got %v", peers, got)
+	}
+}
+
+func TestPeers6RoundTrip(t *testing.T) {
+	peers := []netip.AddrPort{netip.MustParseAddrPort("[2001:db8::1]:6881")}
+	s, err := compact.PackPeers6(peers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(s) != compact.Peer6Len {
+		t.Fatalf("Expected %d bytes, got %d", compact.Peer6Len, len(s))
+	}
+
+	got, err := compact.UnpackPeers6(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0] != peers[0] {
+		t.Fatalf("Expected %v, got %v", peers, got)
+	}
+}
+
+func TestPeersWrongFamily(t *testing.T) {
+	if _, err := compact.PackPeers([]netip.AddrPort{netip.MustParseAddrPort("[::1]:1")}); err == nil {
+		t.Fatal("Expected error, got nil")
+	}
+	if _, err := compact.PackPeers6([]netip.AddrPort{netip.MustParseAddrPort("1.2.3.4:1")}); err == nil {
+		t.Fatal("Expected error, got nil")
+	}
+}
+
+func TestPeersInvalidLength(t *testing.T) {
+	_, err := compact.UnpackPeers("12345")
+	if err == nil {
+		t.Fatal("Expected error, got nil")
+	}
+	t.Log(err)
+}
@@ -0,0 +1,291 @@
+package bencode_test
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/deathcrafter/bencode"
+)
+
+type corpusCase struct {
+	line     int
+	outcome  string
+	knownBug bool
+	data     []byte
+}
+
+func readCorpus(t testing.TB) []corpusCase {
+	f, err := os.Open("testdata/conformance.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var cases []corpusCase
+	s := bufio.NewScanner(f)
+	for line := 1; s.Scan(); line++ {
+		text := strings.TrimSpace(s.Text())
+		if text == "" || text[0] == '#' {
+			continue
+		}
+		c := corpusCase{line: line}
+		c.outcome, text, _ = strings.Cut(text, " ")
+		if strings.HasPrefix(c.outcome, "!") {
+			c.outcome, c.knownBug = c.outcome[1:], true
+		}
+
+		for text = strings.TrimSpace(text); text != "" && text[0] != '#'; text = strings.TrimSpace(text) {
+			quoted, err := strconv.QuotedPrefix(text)
+			if err != nil {
+				t.Fatalf("line %d: %s", line, err)
+			}
+			part, _ := strconv.Unquote(quoted)
+			text = text[len(quoted):]
+
+			n := 1
+			if strings.HasPrefix(text, "*") {
+				digits, rest, _ := strings.Cut(text[1:], " ")
+				if n, err = strconv.Atoi(digits); err != nil {
+					t.Fatalf("line %d: %s", line, err)
+				}
+				text = rest
+			}
+			c.data = append(c.data, strings.Repeat(part, n)...)
+		}
+		cases = append(cases, c)
+	}
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return cases
+}
+
+func decodeOutcome(data []byte) (string, error) {
+	b, err := bencode.Decode(data)
+	if err != nil {
+		return "error", nil
+	}
+	e, err := b.Encode()
+	if err != nil {
+		return "", err
+	}
+	if bytes.Equal(e, data) {
+		return "ok", nil
+	}
+	return "lenient", nil
+}
+
+func TestConformance(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "case")
+	for _, c := range readCorpus(t) {
+		got, err := decodeOutcome(c.data)
+		if err != nil {
+			t.Fatalf("line %d: decoded but cannot encode: %s", c.line, err)
+		}
+
+		switch {
+		case c.knownBug && got == c.outcome:
+			t.Errorf("line %d: known bug is fixed, remove its marker", c.line)
+		case c.knownBug:
+			t.Logf("line %d: known bug, expected %s, got %s", c.line, c.outcome, got)
+		case got != c.outcome:
+			t.Errorf("line %d: expected %s, got %s", c.line, c.outcome, got)
+		}
+
+		// only canonical documents pass CheckCanonical
+		if canonical := bencode.CheckCanonical(c.data) == nil; canonical != (c.outcome == "ok") {
+			t.Errorf("line %d: CheckCanonical reports canonical %v for %s", c.line, canonical, c.outcome)
+		}
+
+		// every other decoder accepts what Decode accepts, with the same
+		// element
+		expected, _, err := bencode.DecodePrefix(c.data)
+		accepted := err == nil
+		check := func(decoder string, b bencode.Belement, err error) {
+			t.Helper()
+			switch {
+			case (err == nil) != accepted:
+				t.Errorf("line %d: %s error %v, Decode accepts %v", c.line, decoder, err, accepted)
+			case err == nil && !bencode.Equal(b, expected):
+				e1, _ := expected.Encode()
+				e2, _ := b.Encode()
+				t.Errorf("line %d: %s decodes to %q, Decode to %q", c.line, decoder, e2, e1)
+			}
+		}
+
+		raw, err := bencode.NewScanner(c.data).Raw()
+		var b bencode.Belement
+		if err == nil {
+			b, err = bencode.Decode(raw)
+		}
+		check("Scanner", b, err)
+
+		h := &treeHandler{}
+		err = bencode.DecodeEvents(bytes.NewReader(c.data), h)
+		check("DecodeEvents", h.root, err)
+
+		// a Parser fed one byte at a time yields the element first
+		p := bencode.Parser{}
+		var values []bencode.Belement
+		err = nil
+		for i := 0; i < len(c.data) && len(values) == 0 && err == nil; i++ {
+			values, err = p.Feed(c.data[i : i+1])
+		}
+		if len(values) != 0 {
+			check("Parser", values[0], nil)
+		} else {
+			if err == nil {
+				err = p.Finish()
+			}
+			if err == nil {
+				err = errors.New("no element")
+			}
+			check("Parser", bencode.InvalidBelement, err)
+		}
+
+		lazy, err := bencode.DecodeLazy(c.data)
+		check("DecodeLazy", lazy, err)
+		if err == nil {
+			e1, _ := expected.Encode()
+			e2, _ := lazy.Encode()
+			if !bytes.Equal(e1, e2) {
+				t.Errorf("line %d: DecodeLazy encodes to %q, Decode to %q", c.line, e2, e1)
+			}
+		}
+
+		if err := os.WriteFile(file, c.data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		f, err := bencode.DecodeFile(file, bencode.FileOptions{})
+		if err == nil {
+			check("DecodeFile", f.Root, nil)
+			f.Close()
+		} else {
+			check("DecodeFile", bencode.InvalidBelement, err)
+		}
+	}
+}
+
+// treeHandler builds the element DecodeEvents reports.
+type treeHandler struct {
+	root  bencode.Belement
+	stack []bencode.Belement // lists and dicts in progress
+	keys  []string           // of the value in progress in each of stack
+}
+
+func (h *treeHandler) add(b bencode.Belement) error {
+	if len(h.stack) == 0 {
+		h.root = b
+		return nil
+	}
+	top := &h.stack[len(h.stack)-1]
+	if top.Type == bencode.TypeList {
+		top.Value = append(top.Value.([]bencode.Belement), b)
+	} else {
+		top.Value.(map[string]bencode.Belement)[h.keys[len(h.keys)-1]] = b
+	}
+	return nil
+}
+
+func (h *treeHandler) StartDict(p bencode.Path, offset int) error {
+	h.stack = append(h.stack, bencode.Belement{Type: bencode.TypeDict, Value: map[string]bencode.Belement{}})
+	h.keys = append(h.keys, "")
+	return nil
+}
+
+func (h *treeHandler) Key(p bencode.Path, key string, offset int) error {
+	h.keys[len(h.keys)-1] = key
+	return nil
+}
+
+func (h *treeHandler) StartList(p bencode.Path, offset int) error {
+	h.stack = append(h.stack, bencode.Belement{Type: bencode.TypeList, Value: []bencode.Belement{}})
+	h.keys = append(h.keys, "")
+	return nil
+}
+
+func (h *treeHandler) Int(p bencode.Path, v int, offset int) error {
+	return h.add(bencode.Belement{Type: bencode.TypeInt, Value: v})
+}
+
+func (h *treeHandler) String(p bencode.Path, s string, offset int) error {
+	return h.add(bencode.Belement{Type: bencode.TypeString, Value: s})
+}
+
+func (h *treeHandler) End(p bencode.Path, offset int) error {
+	b := h.stack[len(h.stack)-1]
+	h.stack, h.keys = h.stack[:len(h.stack)-1], h.keys[:len(h.keys)-1]
+	return h.add(b)
+}
+
+func addCorpus(f *testing.F) {
+	for _, c := range readCorpus(f) {
+		if !c.knownBug {
+			f.Add(c.data)
+		}
+	}
+}
+
+func FuzzDecode(f *testing.F) {
+	addCorpus(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		b, rest, err := bencode.DecodePrefix(data)
+
+		// the decoder reads the elements the scanner does, and the same bytes
+		raw, serr := bencode.NewScanner(data).Raw()
+		if (serr == nil) != (err == nil) {
+			t.Fatalf("DecodePrefix error %v, Scanner error %v", err, serr)
+		}
+		if err != nil {
+			if bencode.CheckCanonical(data) == nil {
+				t.Fatalf("Canonical document rejected: %v", err)
+			}
+			return
+		}
+		if len(raw)+len(rest) != len(data) {
+			t.Fatalf("DecodePrefix read %d bytes, Scanner %d", len(data)-len(rest), len(raw))
+		}
+
+		e, err := b.Encode()
+		if err != nil {
+			t.Fatalf("Decoded but cannot encode: %v", err)
+		}
+		if bencode.CheckCanonical(data) == nil && !bytes.Equal(e, data) {
+			t.Fatalf("Canonical document encodes to %q", e)
+		}
+	})
+}
+
+func FuzzRoundTrip(f *testing.F) {
+	addCorpus(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		b, err := bencode.Decode(data)
+		if err != nil {
+			return
+		}
+		e, err := b.Encode()
+		if err != nil {
+			t.Fatalf("Decoded but cannot encode: %v", err)
+		}
+		if err := bencode.CheckCanonical(e); err != nil {
+			t.Fatalf("Encode is not canonical: %v", err)
+		}
+
+		b2, err := bencode.Decode(e)
+		if err != nil {
+			t.Fatalf("Cannot decode own encoding %q: %v", e, err)
+		}
+		if !bencode.Equal(b, b2) {
+			t.Fatalf("Round trip changed %v to %v", b, b2)
+		}
+		if e2, _ := b2.Encode(); !bytes.Equal(e, e2) {
+			t.Fatalf("Encoding is not stable: %q then %q", e, e2)
+		}
+	})
+}
@@ -4,8 +4,6 @@ import (
 	"bytes"
 	"fmt"
 	"io"
-	"strconv"
-	"strings"
 )
 
 func safeSubbyte(b []byte, start int, end int) []byte {
@@ -21,14 +19,32 @@ func safeSubbyte(b []byte, start int, end int) []byte {
 	return b[start:end]
 }
 
+// Decode decodes the first element of data and ignores anything after it,
+// such as the newline some trackers end responses with. It is lenient:
+// integers and string lengths may have leading zeros or a sign, and dict
+// keys may be in any order. Every decoder in the package accepts the same
+// documents; use CheckCanonical to require exactly one element in canonical
+// form.
 func Decode(data []byte) (Belement, error) {
-	var decode func([]byte) (Belement, []byte, error)
-	decode = func(data []byte) (Belement, []byte, error) {
+	ret, _, err := DecodePrefix(data)
+	return ret, err
+}
+
+// DecodePrefix decodes the first element of data and returns the bytes that
+// follow it, for messages that carry a raw payload after a bencoded header.
+func DecodePrefix(data []byte) (Belement, []byte, error) {
+	whole := data
+	// depth counts the lists and dicts around data
+	var decode func(data []byte, depth int) (Belement, []byte, error)
+	decode = func(data []byte, depth int) (Belement, []byte, error) {
 		belement := Belement{Type: TypeInvalid}
 
 		if len(data) == 0 {
 			return belement, nil, BencodeError{msg: "Empty value"}
 		}
+		if (data[0] == 'l' || data[0] == 'd') && depth == MaxDepth {
+			return belement, nil, SyntaxError{Offset: len(whole) - len(data), msg: fmt.Sprintf("Nesting deeper than %d", MaxDepth)}
+		}
 
 		for len(data) > 0 {
 			switch data[0] {
@@ -38,9 +54,9 @@ func Decode(data []byte) (Belement, error) {
 					return belement, nil, BencodeError{msg: "Invalid integer format: missing end of element"}
 				}
 
-				v, err := strconv.Atoi(string(data[1:end]))
+				v, _, err := parseInt(data[1:end])
 				if err != nil {
-					return belement, nil, BencodeError{msg: fmt.Sprintf("Invalid integer format: %s", err.Error())}
+					return belement, nil, err
 				}
 
 				belement = Belement{Type: TypeInt, Value: v}
@@ -58,7 +74,7 @@ func Decode(data []byte) (Belement, error) {
 						break
 					}
 
-					elem, newData, err := decode(data)
+					elem, newData, err := decode(data, depth+1)
 					if err != nil {
 						return belement, nil, err
 					}
@@ -75,63 +91,60 @@ func Decode(data []byte) (Belement, error) {
 				return belement, data, nil
 			case 'd':
 				dict := make(map[string]Belement)
-				metEnd := false
-				key := ""
 
 				data = safeSubbyte(data, 1, -1) // skip 'd'
 
-				for len(data) > 0 {
+				for {
+					if len(data) == 0 {
+						return belement, nil, BencodeError{msg: "Invalid dict format: missing end of dict"}
+					}
 					if data[0] == 'e' { // end of dict
 						data = safeSubbyte(data, 1, -1)
-						metEnd = true
 						break
 					}
 
-					v, newData, err := decode(data)
+					// a key and its value are read together, so any string,
+					// including an empty one, is a valid key
+					k, newData, err := decode(data, depth+1)
 					if err != nil {
 						return belement, nil, err
 					}
-					data = newData
-
-					if len(key) == 0 { // key is not set yet, v must be a string
-						key, err = v.GetString()
-						if err != nil {
-							return belement, nil, BencodeError{msg: fmt.Sprintf("Invalid dict key: %s", err.Error())}
-						}
-					} else { // put in dict and reset key
-						dict[key] = v
-						key = ""
+					key, err := k.GetString()
+					if err != nil {
+						return belement, nil, BencodeError{msg: fmt.Sprintf("Invalid dict key: %s", err.Error())}
 					}
-				}
 
-				if len(key) != 0 {
-					return belement, nil, BencodeError{msg: "Invalid dict format: missing value"}
-				}
+					if len(newData) == 0 || newData[0] == 'e' {
+						return belement, nil, BencodeError{msg: "Invalid dict format: missing value"}
+					}
+					v, newData, err := decode(newData, depth+1)
+					if err != nil {
+						return belement, nil, err
+					}
+					data = newData
 
-				if !metEnd {
-					return belement, nil, BencodeError{msg: "Invalid dict format: missing end of dict"}
+					dict[key] = v
 				}
 
 				belement = Belement{Type: TypeDict, Value: dict}
-				return belement, []byte(data), nil
+				return belement, data, nil
 			default:
 				// data must be a string
-				raw := string(data)
-				eol := strings.Index(raw, ":") // end of length
+				eol := bytes.IndexByte(data, ':') // end of length
 				if eol == -1 {
 					return belement, nil, BencodeError{msg: "Invalid string format"}
 				}
 
-				length, err := strconv.Atoi(raw[:eol])
+				length, _, err := parseLength(data[:eol])
 				if err != nil {
-					return belement, nil, BencodeError{msg: fmt.Sprintf("Invalid string format. Invalid length: %s", err.Error())}
+					return belement, nil, err
 				}
 
-				if eol+1+length > len(raw) {
+				if length > len(data)-eol-1 {
 					return belement, nil, BencodeError{msg: "Invalid string format. Length mismatch"}
 				}
 
-				str := raw[eol+1 : eol+1+length]
+				str := string(data[eol+1 : eol+1+length])
 
 				belement = Belement{Type: TypeString, Value: str}
 				return belement, safeSubbyte(data, eol+1+length, -1), nil
@@ -141,22 +154,13 @@ func Decode(data []byte) (Belement, error) {
 		return belement, nil, BencodeError{msg: "Unknown type"}
 	}
 
-	ret, _, err := decode(data)
-	return ret, err
+	return decode(data, 0)
 }
 
 func DecodeReader(reader io.Reader) (Belement, error) {
-	data := make([]byte, 0)
-	for {
-		slice := make([]byte, 1024)
-		n, err := reader.Read(data)
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
-			return InvalidBelement, err
-		}
-		data = append(data, slice[:n]...)
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return InvalidBelement, err
 	}
 
 	return Decode(data)
@@ -1,7 +1,9 @@
 package bencode_test
 
 import (
+	"strings"
 	"testing"
+	"testing/iotest"
 
 	"github.com/deathcrafter/bencode"
 )
@@ -228,3 +230,122 @@ func TestDecoderDictValueInvalid(t *testing.T) {
 	}
 	t.Log(err)
 }
+
+func TestDecodePrefix(t *testing.T) {
+	b, rest, err := bencode.DecodePrefix([]byte("d3:abci1eeRAW"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v, err := b.GetDictInt("abc"); v != 1 || err != nil {
+		t.Fatalf("Expected value %d, got %d", 1, v)
+	}
+
+	if string(rest) != "RAW" {
+		t.Fatalf("Expected remainder %s, got %s", "RAW", string(rest))
+	}
+}
+
+func TestDecoderTrailingDataIgnored(t *testing.T) {
+	b, err := bencode.Decode([]byte("d3:abci1ee\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := b.GetDictInt("abc"); v != 1 || err != nil {
+		t.Fatalf("Expected value %d, got %d", 1, v)
+	}
+
+	// the strict checks still report it
+	err = bencode.CheckCanonical([]byte("d3:abci1eeRAW"))
+	if serr, ok := err.(bencode.SyntaxError); !ok || serr.Offset != 10 {
+		t.Fatalf("Expected syntax error at offset 10, got %v", err)
+	}
+}
+
+func TestDecoderLenientIntegers(t *testing.T) {
+	for data, expected := range map[string]int{"i03e": 3, "i-0e": 0, "i+3e": 3} {
+		b, err := bencode.Decode([]byte(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v, err := b.GetInt(); v != expected || err != nil {
+			t.Fatalf("%q: expected %d, got %d", data, expected, v)
+		}
+	}
+	if _, err := bencode.Decode([]byte("-1:a")); err == nil {
+		t.Fatal("Expected error for negative length, got nil")
+	}
+}
+
+func TestDecodeReader(t *testing.T) {
+	// larger than one read, so the input arrives in pieces
+	data := "l" + strings.Repeat("3:abc", 1000) + "e"
+	b, err := bencode.DecodeReader(iotest.OneByteReader(strings.NewReader(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if l, err := b.GetList(); err != nil || len(l) != 1000 {
+		t.Fatalf("Expected list of %d, got %v", 1000, b)
+	}
+}
+
+func TestDecoderDictEmptyKey(t *testing.T) {
+	b, err := bencode.Decode([]byte("d0:i1e1:ai2ee"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, _ := b.GetDict()
+	if len(d) != 2 {
+		t.Fatalf("Expected %d keys, got %d", 2, len(d))
+	}
+	if v, err := b.GetDictInt(""); v != 1 || err != nil {
+		t.Fatalf("Expected value %d for empty key, got %d", 1, v)
+	}
+	if v, err := b.GetDictInt("a"); v != 2 || err != nil {
+		t.Fatalf("Expected value %d, got %d", 2, v)
+	}
+}
+
+func TestDecoderDictEmptyValue(t *testing.T) {
+	b, err := bencode.Decode([]byte("d0:0:1:a0:e"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, k := range []string{"", "a"} {
+		if v, err := b.GetDictString(k); v != "" || err != nil {
+			t.Fatalf("Expected empty string for key %q, got %q, %v", k, v, err)
+		}
+	}
+}
+
+func TestDecoderDictBinaryKey(t *testing.T) {
+	b, err := bencode.Decode([]byte("d2:\x00\xffi1e1:ei2ee"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v, err := b.GetDictInt("\x00\xff"); v != 1 || err != nil {
+		t.Fatalf("Expected value %d, got %d", 1, v)
+	}
+	// a key that reads like the end of the dict
+	if v, err := b.GetDictInt("e"); v != 2 || err != nil {
+		t.Fatalf("Expected value %d, got %d", 2, v)
+	}
+}
+
+func TestDecoderNestingTooDeep(t *testing.T) {
+	n := 10000000
+	data := []byte(strings.Repeat("l", n) + strings.Repeat("e", n))
+
+	_, err := bencode.Decode(data)
+	serr, ok := err.(bencode.SyntaxError)
+	if !ok || serr.Offset != bencode.MaxDepth || !strings.Contains(err.Error(), "Nesting deeper than") {
+		t.Fatalf("Expected nesting error at offset %d, got %v", bencode.MaxDepth, err)
+	}
+	if err := bencode.CheckCanonical(data); err == nil || err.Error() != serr.Error() {
+		t.Fatalf("Expected %v from CheckCanonical, got %v", serr, err)
+	}
+}
@@ -0,0 +1,155 @@
+package bencode
+
+import (
+	"fmt"
+	"strings"
+)
+
+type ChangeOp int
+
+const (
+	ChangeAdd ChangeOp = iota + 1
+	ChangeRemove
+	ChangeReplace
+)
+
+func (o ChangeOp) String() string {
+	switch o {
+	case ChangeAdd:
+		return "add"
+	case ChangeRemove:
+		return "remove"
+	case ChangeReplace:
+		return "replace"
+	default:
+		return "unknown"
+	}
+}
+
+// Change is a single edit. Old is InvalidBelement for adds and New is
+// InvalidBelement for removes.
+type Change struct {
+	Op   ChangeOp
+	Path Path
+	Old  Belement
+	New  Belement
+}
+
+func (c Change) String() string {
+	switch c.Op {
+	case ChangeAdd:
+		return fmt.Sprintf("+ %s: %s", c.Path, diffValue(c.New))
+	case ChangeRemove:
+		return fmt.Sprintf("- %s: %s", c.Path, diffValue(c.Old))
+	case ChangeReplace:
+		return fmt.Sprintf("~ %s: %s -> %s", c.Path, diffValue(c.Old), diffValue(c.New))
+	default:
+		return fmt.Sprintf("? %s", c.Path)
+	}
+}
+
+func diffValue(v Belement) string {
+	j, err := ToJSON(v)
+	if err != nil {
+		return v.Type.String()
+	}
+	return string(j)
+}
+
+// FormatDiff renders changes one per line, prefixed with +, - or ~.
+func FormatDiff(changes []Change) string {
+	b := strings.Builder{}
+	for _, c := range changes {
+		b.WriteString(c.String() + "\n")
+	}
+	return b.String()
+}
+
+// Diff returns the changes that turn a into b. Dict keys are visited in byte
+// order. Lists are compared by index, so removals at the end of a list are
+// listed from the highest index down and Apply can replay them in order.
+func Diff(a, b Belement) []Change {
+	return diff(a, b, Path{}, make([]Change, 0))
+}
+
+func diff(a, b Belement, p Path, changes []Change) []Change {
+	if a.Type != b.Type {
+		return append(changes, Change{Op: ChangeReplace, Path: p, Old: a, New: b})
+	}
+
+	switch a.Type {
+	case TypeInt, TypeString:
+		if !Equal(a, b) {
+			changes = append(changes, Change{Op: ChangeReplace, Path: p, Old: a, New: b})
+		}
+	case TypeList:
+		x, _ := a.GetList()
+		y, _ := b.GetList()
+		for i := 0; i < len(x) && i < len(y); i++ {
+			changes = diff(x[i], y[i], p.Index(i), changes)
+		}
+		for i := len(x) - 1; i >= len(y); i-- {
+			changes = append(changes, Change{Op: ChangeRemove, Path: p.Index(i), Old: x[i], New: InvalidBelement})
+		}
+		for i := len(x); i < len(y); i++ {
+			changes = append(changes, Change{Op: ChangeAdd, Path: p.Index(i), Old: InvalidBelement, New: y[i]})
+		}
+	case TypeDict:
+		x, _ := a.GetDict()
+		y, _ := b.GetDict()
+		union := make(map[string]struct{}, len(x)+len(y))
+		for k := range x {
+			union[k] = struct{}{}
+		}
+		for k := range y {
+			union[k] = struct{}{}
+		}
+
+		for _, k := range sortedKeys(union) {
+			xv, inX := x[k]
+			yv, inY := y[k]
+			switch {
+			case !inY:
+				changes = append(changes, Change{Op: ChangeRemove, Path: p.Key(k), Old: xv, New: InvalidBelement})
+			case !inX:
+				changes = append(changes, Change{Op: ChangeAdd, Path: p.Key(k), Old: InvalidBelement, New: yv})
+			default:
+				changes = diff(xv, yv, p.Key(k), changes)
+			}
+		}
+	}
+	return changes
+}
+
+// Apply replays changes on v and returns the patched copy. It fails when a
+// change does not fit v, such as removing a missing key or adding one that
+// already exists.
+func Apply(v Belement, changes []Change) (Belement, error) {
+	var err error
+	for _, c := range changes {
+		_, getErr := v.Get(c.Path)
+		switch c.Op {
+		case ChangeAdd:
+			if getErr == nil {
+				return InvalidBelement, BencodeError{msg: fmt.Sprintf("Cannot add %s: value already exists", c.Path)}
+			}
+			v, err = v.Set(c.Path, c.New)
+		case ChangeRemove:
+			if getErr != nil {
+				return InvalidBelement, BencodeError{msg: fmt.Sprintf("Cannot remove %s", getErr.Error())}
+			}
+			v, err = v.Delete(c.Path)
+		case ChangeReplace:
+			if getErr != nil {
+				return InvalidBelement, BencodeError{msg: fmt.Sprintf("Cannot replace %s", getErr.Error())}
+			}
+			v, err = v.Set(c.Path, c.New)
+		default:
+			return InvalidBelement, BencodeError{msg: fmt.Sprintf("Unknown change %d at %s", c.Op, c.Path)}
+		}
+		if err != nil {
+			return InvalidBelement, err
+		}
+	}
+	return v, nil
+}
@@ -0,0 +1,79 @@
+package bencode_test
+
+import (
+	"testing"
+
+	"github.com/deathcrafter/bencode"
+)
+
+func TestDiffApply(t *testing.T) {
+	a, err := bencode.Decode([]byte("d8:announce8:http://a4:infod6:lengthi1e4:name5:a.txte4:listli1ei2ei3eee"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := bencode.Decode([]byte("d7:comment2:hi4:infod6:lengthi1e4:name5:b.txte4:listli1ei9eee"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes := bencode.Diff(a, b)
+	expected := `- .announce: "http://a"
++ .comment: "hi"
+~ .info.name: "a.txt" -> "b.txt"
+~ .list[1]: 2 -> 9
+- .list[2]: 3
+`
+	if s := bencode.FormatDiff(changes); s != expected {
+		t.Fatalf("Expected\n%s\ngot\n%s", expected, s)
+	}
+
+	patched, err := bencode.Apply(a, changes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := bencode.Diff(patched, b); len(d) != 0 {
+		t.Fatalf("Expected no differences, got\n%s", bencode.FormatDiff(d))
+	}
+}
+
+func TestDiffListGrowAndShrink(t *testing.T) {
+	a, _ := bencode.Decode([]byte("li1ei2ei3ei4ee"))
+	b, _ := bencode.Decode([]byte("li1ee"))
+
+	for _, pair := range [][2]bencode.Belement{{a, b}, {b, a}} {
+		changes := bencode.Diff(pair[0], pair[1])
+		patched, err := bencode.Apply(pair[0], changes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d := bencode.Diff(patched, pair[1]); len(d) != 0 {
+			t.Fatalf("Expected no differences, got\n%s", bencode.FormatDiff(d))
+		}
+	}
+}
+
+func TestDiffTypeChange(t *testing.T) {
+	a, _ := bencode.Decode([]byte("d1:ai1ee"))
+	b, _ := bencode.Decode([]byte("d1:ali1eee"))
+
+	changes := bencode.Diff(a, b)
+	if len(changes) != 1 || changes[0].Op != bencode.ChangeReplace || changes[0].Path.String() != ".a" {
+		t.Fatalf("Unexpected changes %v", changes)
+	}
+}
+
+func TestApplyInvalid(t *testing.T) {
+	a, _ := bencode.Decode([]byte("d1:ai1ee"))
+
+	for _, c := range []bencode.Change{
+		{Op: bencode.ChangeAdd, Path: bencode.Path{}.Key("a"), New: a},
+		{Op: bencode.ChangeRemove, Path: bencode.Path{}.Key("b")},
+		{Op: bencode.ChangeReplace, Path: bencode.Path{}.Key("b"), New: a},
+	} {
+		if _, err := bencode.Apply(a, []bencode.Change{c}); err == nil {
+			t.Fatalf("Expected error for %s, got nil", c)
+		} else {
+			t.Log(err)
+		}
+	}
+}
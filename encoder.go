@@ -2,7 +2,6 @@ package bencode
 
 import (
 	"fmt"
-	"sort"
 	"strconv"
 )
 
@@ -10,181 +9,149 @@ type Encodable interface {
 	Encode() ([]byte, error)
 }
 
-func EncodeInt(v int) ([]byte, error) {
-	ret := make([]byte, 0)
-	ret = append(ret, byte('i'))
-	ret = append(ret, []byte(strconv.Itoa(v))...)
-	ret = append(ret, byte('e'))
-	return ret, nil
+// AppendInt appends the encoding of v to dst, in the style of
+// strconv.AppendInt.
+func AppendInt(dst []byte, v int64) []byte {
+	dst = append(dst, 'i')
+	dst = strconv.AppendInt(dst, v, 10)
+	return append(dst, 'e')
 }
 
-func EncodeString(v string) ([]byte, error) {
-	ret := make([]byte, 0)
-	ret = append(ret, []byte(strconv.Itoa(len(v)))...)
-	ret = append(ret, byte(':'))
-	ret = append(ret, []byte(v)...)
-	return ret, nil
+func AppendString(dst []byte, v string) []byte {
+	dst = strconv.AppendInt(dst, int64(len(v)), 10)
+	dst = append(dst, ':')
+	return append(dst, v...)
 }
 
-func EncodeList(b []interface{}) ([]byte, error) {
-	ret := make([]byte, 0)
-	ret = append(ret, byte('l'))
-
-	// Empty list
-	if len(b) == 0 {
-		ret = append(ret, byte('e'))
-		return ret, nil
-	}
+func AppendBytes(dst []byte, v []byte) []byte {
+	dst = strconv.AppendInt(dst, int64(len(v)), 10)
+	dst = append(dst, ':')
+	return append(dst, v...)
+}
 
-	// since Go slices can contain only one type, we can switch over the type of the first element
-	for _, v := range b {
-		switch t := v.(type) {
-		case int:
-			e, err := EncodeInt(v.(int))
-			if err != nil {
-				return nil, err
-			}
-			ret = append(ret, e...)
-		case string:
-			e, err := EncodeString(v.(string))
-			if err != nil {
-				return nil, err
-			}
-			ret = append(ret, e...)
-		case map[string]interface{}:
-			e, err := EncodeDict(v.(map[string]interface{}))
-			if err != nil {
-				return nil, err
-			}
-			ret = append(ret, e...)
-		case []interface{}:
-			e, err := EncodeList(v.([]interface{}))
-			if err != nil {
-				return nil, err
-			}
-			ret = append(ret, e...)
-		case Belement:
-			e, err := (v.(Belement)).Encode()
-			if err != nil {
-				return nil, err
-			}
-			ret = append(ret, e...)
-		default:
-			return nil, BencodeError{msg: fmt.Sprintf("Expected list value of int, string or Belement. Recieved %T", t)}
+// AppendValue appends the encoding of v to dst. It takes the types accepted
+// by EncodeList and EncodeDict, other Encodable values, and anything else
+// Marshal can encode.
+func AppendValue(dst []byte, v interface{}) ([]byte, error) {
+	switch t := v.(type) {
+	case int:
+		return AppendInt(dst, int64(t)), nil
+	case int64:
+		return AppendInt(dst, t), nil
+	case string:
+		return AppendString(dst, t), nil
+	case []byte:
+		return AppendBytes(dst, t), nil
+	case Belement:
+		return appendBelement(dst, t)
+	case []interface{}:
+		return appendList(dst, t)
+	case map[string]interface{}:
+		return appendDict(dst, t)
+	case []Belement:
+		return appendBelement(dst, Belement{Type: TypeList, Value: t})
+	case map[string]Belement:
+		return appendBelement(dst, Belement{Type: TypeDict, Value: t})
+	case Encodable:
+		e, err := t.Encode()
+		if err != nil {
+			return nil, err
 		}
+		return append(dst, e...), nil
+	default:
+		e, ok, err := appendReflect(dst, v)
+		if err != nil {
+			return nil, BencodeError{msg: fmt.Sprintf("Cannot encode value of type %T: %s", v, err.Error())}
+		}
+		if !ok {
+			return nil, BencodeError{msg: fmt.Sprintf("Cannot encode value of type %T", v)}
+		}
+		return e, nil
 	}
+}
 
-	ret = append(ret, byte('e'))
-	return ret, nil
+func EncodeInt(v int) ([]byte, error) {
+	return AppendInt(nil, int64(v)), nil
 }
 
-func EncodeDict(b map[string]interface{}) ([]byte, error) {
-	ret := make([]byte, 0)
-	ret = append(ret, byte('d'))
+func EncodeString(v string) ([]byte, error) {
+	return AppendString(nil, v), nil
+}
 
-	// Empty dict
-	if len(b) == 0 {
-		ret = append(ret, byte('e'))
-		return ret, nil
-	}
+func EncodeList(b []interface{}) ([]byte, error) {
+	return appendList(nil, b)
+}
 
-	// Bencode specs require dict keys to be sorted
-	sorted_keys := make(sort.StringSlice, 0)
-	for k := range b {
-		sorted_keys = append(sorted_keys, k)
-	}
-	sort.Sort(sorted_keys)
+func EncodeDict(b map[string]interface{}) ([]byte, error) {
+	return appendDict(nil, b)
+}
 
-	for _, k := range sorted_keys {
-		v := b[k]
-		e, err := EncodeString(k)
-		if err != nil {
+func appendList(dst []byte, b []interface{}) ([]byte, error) {
+	dst = append(dst, 'l')
+	for _, v := range b {
+		var err error
+		if dst, err = AppendValue(dst, v); err != nil {
 			return nil, err
 		}
-		ret = append(ret, e...)
+	}
+	return append(dst, 'e'), nil
+}
 
-		switch t := v.(type) {
-		case int:
-			e, err := EncodeInt(v.(int))
-			if err != nil {
-				return nil, err
-			}
-			ret = append(ret, e...)
-		case string:
-			e, err := EncodeString(v.(string))
-			if err != nil {
-				return nil, err
-			}
-			ret = append(ret, e...)
-		case Belement:
-			e, err := (v.(Belement)).Encode()
-			if err != nil {
-				return nil, err
-			}
-			ret = append(ret, e...)
-		case []Belement:
-			x := make([]interface{}, 0)
-			for _, v := range v.([]Belement) {
-				x = append(x, v)
-			}
-			e, err := EncodeList(x)
-			if err != nil {
-				return nil, err
-			}
-			ret = append(ret, e...)
-		case []interface{}:
-			e, err := EncodeList(v.([]interface{}))
-			if err != nil {
-				return nil, err
-			}
-			ret = append(ret, e...)
-		case map[string]Belement:
-			x := make(map[string]interface{}, 0)
-			for k, val := range v.(map[string]Belement) {
-				x[k] = val
-			}
-			e, err := EncodeDict(x)
-			if err != nil {
-				return nil, err
-			}
-			ret = append(ret, e...)
-		case map[string]interface{}:
-			e, err := EncodeDict(v.(map[string]interface{}))
-			if err != nil {
-				return nil, err
-			}
-			ret = append(ret, e...)
-		default:
-			return nil, BencodeError{msg: fmt.Sprintf("Expected dict value of int, string or Belement. Recieved %T", t)}
+func appendDict(dst []byte, b map[string]interface{}) ([]byte, error) {
+	dst = append(dst, 'd')
+	// Bencode specs require dict keys to be sorted
+	for _, k := range sortedKeys(b) {
+		var err error
+		dst = AppendString(dst, k)
+		if dst, err = AppendValue(dst, b[k]); err != nil {
+			return nil, err
 		}
 	}
-
-	ret = append(ret, byte('e'))
-	return ret, nil
+	return append(dst, 'e'), nil
 }
 
-func (v Belement) Encode() ([]byte, error) {
+func appendBelement(dst []byte, v Belement) ([]byte, error) {
+	if raw, ok := lazyRaw(v); ok {
+		return append(dst, raw...), nil
+	}
 	switch v.Type {
 	case TypeInt:
-		r, _ := v.GetInt()
-		return EncodeInt(r)
+		i, ok := intValue(v.Value)
+		if !ok {
+			return nil, BencodeError{msg: fmt.Sprintf("Int Belement holds %T", v.Value)}
+		}
+		return AppendInt(dst, i), nil
 	case TypeString:
-		r, _ := v.GetString()
-		return EncodeString(r)
+		s, ok := stringValue(v.Value)
+		if !ok {
+			return nil, BencodeError{msg: fmt.Sprintf("String Belement holds %T", v.Value)}
+		}
+		return AppendString(dst, s), nil
 	case TypeList:
-		x := make([]interface{}, 0)
-		r, _ := v.GetList()
-		for _, v := range r {
-			x = append(x, v.Value)
+		l, err := v.GetList()
+		if err != nil {
+			return nil, err
+		}
+		dst = append(dst, 'l')
+		for _, e := range l {
+			if dst, err = appendBelement(dst, e); err != nil {
+				return nil, err
+			}
 		}
-		return EncodeList(x)
+		return append(dst, 'e'), nil
 	case TypeDict:
-		x := make(map[string]interface{}, 0)
-		r, _ := v.GetDict()
-		for k, v := range r {
-			x[k] = v
+		d, err := v.GetDict()
+		if err != nil {
+			return nil, err
 		}
-		return EncodeDict(x)
+		dst = append(dst, 'd')
+		for _, k := range sortedKeys(d) {
+			dst = AppendString(dst, k)
+			if dst, err = appendBelement(dst, d[k]); err != nil {
+				return nil, err
+			}
+		}
+		return append(dst, 'e'), nil
 	case TypeInvalid:
 		return nil, BencodeError{msg: "Belement is invalid"}
 	default:
@@ -200,3 +167,7 @@ func (v Belement) Encode() ([]byte, error) {
 		}
 	}
 }
+
+func (v Belement) Encode() ([]byte, error) {
+	return appendBelement(nil, v)
+}
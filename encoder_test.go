@@ -1,6 +1,7 @@
 package bencode_test
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/deathcrafter/bencode"
@@ -134,3 +135,170 @@ func TestEncoderMixed(t *testing.T) {
 
 	t.Logf("Encoded: %s", string(e))
 }
+
+func TestEncoderBencodeListOfDicts(t *testing.T) {
+	b := bencode.Belement{
+		Type: bencode.TypeList,
+		Value: []bencode.Belement{
+			{Type: bencode.TypeDict, Value: map[string]bencode.Belement{"abc": {Type: bencode.TypeInt, Value: 1}}},
+			{Type: bencode.TypeList, Value: []bencode.Belement{{Type: bencode.TypeString, Value: "abc"}}},
+		},
+	}
+	e, err := b.Encode()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(e) != "ld3:abci1eel3:abcee" {
+		t.Fatalf("Expected %s, got %s", "ld3:abci1eel3:abcee", string(e))
+	}
+
+	t.Logf("Encoded: %s", string(e))
+}
+
+type hexKey [2]byte
+
+func (k hexKey) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("%x", k[:])), nil
+}
+
+func (k *hexKey) UnmarshalText(b []byte) error {
+	_, err := fmt.Sscanf(string(b), "%02x%02x", &k[0], &k[1])
+	return err
+}
+
+func TestEncoderTypedCollections(t *testing.T) {
+	b := []interface{}{
+		[]string{"a", "b"},
+		[2]int{1, 2},
+		map[string]int{"z": 1, "a": 2},
+	}
+	e, err := bencode.EncodeList(b)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(e) != "ll1:a1:beli1ei2eed1:ai2e1:zi1eee" {
+		t.Fatalf("Expected %s, got %s", "ll1:a1:beli1ei2eed1:ai2e1:zi1eee", string(e))
+	}
+
+	t.Logf("Encoded: %s", string(e))
+}
+
+func TestEncoderMapKeys(t *testing.T) {
+	b := map[string]interface{}{
+		"hashes": map[[3]byte]int{{0xff, 0, 0}: 1, {'a', 'b', 'c'}: 2},
+		"text":   map[hexKey]string{{0x01, 0xab}: "x"},
+	}
+	e, err := bencode.EncodeDict(b)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "d6:hashesd3:abci2e3:\xff\x00\x00i1ee4:textd4:01ab1:xee"
+	if string(e) != expected {
+		t.Fatalf("Expected %q, got %q", expected, string(e))
+	}
+
+	var x struct {
+		Hashes map[[3]byte]int   `bencode:"hashes"`
+		Text   map[hexKey]string `bencode:"text"`
+	}
+	if err := bencode.Unmarshal(e, &x); err != nil {
+		t.Fatal(err)
+	}
+	if x.Hashes[[3]byte{'a', 'b', 'c'}] != 2 || x.Text[hexKey{0x01, 0xab}] != "x" {
+		t.Fatalf("Unexpected value %+v", x)
+	}
+}
+
+func TestEncoderUnsupported(t *testing.T) {
+	_, err := bencode.EncodeList([]interface{}{make(chan int)})
+	if err == nil {
+		t.Fatal("Expected error, got nil")
+	}
+	t.Log(err)
+
+	_, err = bencode.EncodeDict(map[string]interface{}{"a": map[float64]int{1: 1}})
+	if err == nil {
+		t.Fatal("Expected error, got nil")
+	}
+	t.Log(err)
+}
+
+func TestEncoderAppend(t *testing.T) {
+	dst := []byte("prefix:")
+	dst = bencode.AppendInt(dst, -42)
+	dst = bencode.AppendString(dst, "abc")
+	dst = bencode.AppendBytes(dst, []byte{0x00, 0xff})
+	dst, err := bencode.AppendValue(dst, map[string]interface{}{
+		"b": []interface{}{1, "x"},
+		"a": bencode.Belement{Type: bencode.TypeInt, Value: 2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "prefix:i-42e3:abc2:\x00\xffd1:ai2e1:bli1e1:xee"
+	if string(dst) != expected {
+		t.Fatalf("Expected %q, got %q", expected, string(dst))
+	}
+
+	if _, err := bencode.AppendValue(nil, make(chan int)); err == nil {
+		t.Fatal("Expected error, got nil")
+	}
+}
+
+func benchmarkDict(n int) bencode.Belement {
+	d := make(map[string]bencode.Belement, n)
+	for i := 0; i < n; i++ {
+		d[fmt.Sprintf("key%04d", i)] = bencode.Belement{Type: bencode.TypeString, Value: fmt.Sprintf("value %d", i)}
+	}
+	d["list"] = bencode.Belement{Type: bencode.TypeList, Value: []bencode.Belement{
+		{Type: bencode.TypeInt, Value: 1},
+		{Type: bencode.TypeInt, Value: 2},
+		{Type: bencode.TypeInt, Value: 3},
+	}}
+	return bencode.Belement{Type: bencode.TypeDict, Value: d}
+}
+
+func BenchmarkEncodeDict(b *testing.B) {
+	v := benchmarkDict(100)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := v.Encode(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkAppendValueDict(b *testing.B) {
+	v := benchmarkDict(100)
+	var dst []byte
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var err error
+		if dst, err = bencode.AppendValue(dst[:0], v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkAppendInt(b *testing.B) {
+	dst := make([]byte, 0, 32)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		dst = bencode.AppendInt(dst[:0], int64(i))
+	}
+}
+
+func BenchmarkAppendString(b *testing.B) {
+	dst := make([]byte, 0, 64)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		dst = bencode.AppendString(dst[:0], "announce-list")
+	}
+}
@@ -0,0 +1,138 @@
+package bencode
+
+import (
+	"bytes"
+	"hash/fnv"
+)
+
+// Equal reports whether a and b hold the same value. Integers compare by
+// value whatever their Go integer type, and strings may be string or []byte.
+func Equal(a, b Belement) bool {
+	if a.Type != b.Type {
+		return false
+	}
+
+	switch a.Type {
+	case TypeInt:
+		x, ok1 := intValue(a.Value)
+		y, ok2 := intValue(b.Value)
+		return ok1 && ok2 && x == y
+	case TypeString:
+		x, ok1 := stringValue(a.Value)
+		y, ok2 := stringValue(b.Value)
+		return ok1 && ok2 && x == y
+	case TypeList:
+		x, err1 := a.GetList()
+		y, err2 := b.GetList()
+		if err1 != nil || err2 != nil || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !Equal(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	case TypeDict:
+		x, err1 := a.GetDict()
+		y, err2 := b.GetDict()
+		if err1 != nil || err2 != nil || len(x) != len(y) {
+			return false
+		}
+		for k, xv := range x {
+			yv, ok := y[k]
+			if !ok || !Equal(xv, yv) {
+				return false
+			}
+		}
+		return true
+	default:
+		return true
+	}
+}
+
+// Compare orders a and b by their canonical encodings, byte by byte, and
+// returns -1, 0 or +1. Invalid elements sort first.
+func Compare(a, b Belement) int {
+	return bytes.Compare(appendCanonical(nil, a), appendCanonical(nil, b))
+}
+
+// Hash returns the 64-bit FNV-1a hash of the canonical encoding of v. It is
+// stable across processes and Equal values have equal hashes.
+func Hash(v Belement) uint64 {
+	h := fnv.New64a()
+	h.Write(appendCanonical(nil, v))
+	return h.Sum64()
+}
+
+func intValue(v interface{}) (int64, bool) {
+	switch t := v.(type) {
+	case int:
+		return int64(t), true
+	case int8:
+		return int64(t), true
+	case int16:
+		return int64(t), true
+	case int32:
+		return int64(t), true
+	case int64:
+		return t, true
+	case uint8:
+		return int64(t), true
+	case uint16:
+		return int64(t), true
+	case uint32:
+		return int64(t), true
+	case uint:
+		return int64(t), t <= 1<<63-1
+	case uint64:
+		return int64(t), t <= 1<<63-1
+	default:
+		return 0, false
+	}
+}
+
+func stringValue(v interface{}) (string, bool) {
+	switch t := v.(type) {
+	case string:
+		return t, true
+	case []byte:
+		return string(t), true
+	default:
+		return "", false
+	}
+}
+
+// appendCanonical writes the canonical encoding of v, leaving out values
+// that cannot be encoded.
+func appendCanonical(dst []byte, v Belement) []byte {
+	if raw, ok := lazyRaw(v); ok {
+		return append(dst, raw...)
+	}
+	switch v.Type {
+	case TypeInt:
+		if i, ok := intValue(v.Value); ok {
+			dst = AppendInt(dst, i)
+		}
+	case TypeString:
+		if s, ok := stringValue(v.Value); ok {
+			dst = AppendString(dst, s)
+		}
+	case TypeList:
+		l, _ := v.GetList()
+		dst = append(dst, 'l')
+		for _, e := range l {
+			dst = appendCanonical(dst, e)
+		}
+		dst = append(dst, 'e')
+	case TypeDict:
+		d, _ := v.GetDict()
+		dst = append(dst, 'd')
+		for _, k := range sortedKeys(d) {
+			dst = AppendString(dst, k)
+			dst = appendCanonical(dst, d[k])
+		}
+		dst = append(dst, 'e')
+	}
+	return dst
+}
@@ -0,0 +1,69 @@
+package bencode_test
+
+import (
+	"testing"
+
+	"github.com/deathcrafter/bencode"
+)
+
+func TestEqual(t *testing.T) {
+	a, _ := bencode.Decode([]byte("d1:ali1e3:abce1:bde1:ci-5ee"))
+	b := bencode.Belement{Type: bencode.TypeDict, Value: map[string]bencode.Belement{
+		"a": {Type: bencode.TypeList, Value: []bencode.Belement{
+			{Type: bencode.TypeInt, Value: int64(1)},
+			{Type: bencode.TypeString, Value: []byte("abc")},
+		}},
+		"b": {Type: bencode.TypeDict, Value: map[string]bencode.Belement{}},
+		"c": {Type: bencode.TypeInt, Value: int8(-5)},
+	}}
+
+	if !bencode.Equal(a, b) {
+		t.Fatalf("Expected %v to equal %v", a, b)
+	}
+	if bencode.Hash(a) != bencode.Hash(b) {
+		t.Fatalf("Expected equal hashes, got %x and %x", bencode.Hash(a), bencode.Hash(b))
+	}
+	if bencode.Compare(a, b) != 0 {
+		t.Fatalf("Expected Compare to return 0, got %d", bencode.Compare(a, b))
+	}
+}
+
+func TestNotEqual(t *testing.T) {
+	for _, pair := range [][2]string{
+		{"i1e", "i2e"},
+		{"i1e", "1:1"},
+		{"li1ee", "li1ei2ee"},
+		{"d1:ai1ee", "d1:bi1ee"},
+		{"d1:ai1ee", "d1:ai2ee"},
+		{"0:", "le"},
+	} {
+		a, _ := bencode.Decode([]byte(pair[0]))
+		b, _ := bencode.Decode([]byte(pair[1]))
+		if bencode.Equal(a, b) {
+			t.Fatalf("Expected %s and %s to differ", pair[0], pair[1])
+		}
+		if bencode.Compare(a, b) == 0 {
+			t.Fatalf("Expected Compare(%s, %s) != 0", pair[0], pair[1])
+		}
+	}
+}
+
+func TestCompareOrder(t *testing.T) {
+	// sorted by encoded bytes
+	sorted := []string{"1:a", "2:ab", "d1:ai1ee", "de", "i-1e", "i10e", "i9e", "le"}
+	for i := 1; i < len(sorted); i++ {
+		a, _ := bencode.Decode([]byte(sorted[i-1]))
+		b, _ := bencode.Decode([]byte(sorted[i]))
+		if bencode.Compare(a, b) != -1 || bencode.Compare(b, a) != 1 {
+			t.Fatalf("Expected %s < %s", sorted[i-1], sorted[i])
+		}
+	}
+}
+
+func TestHashStable(t *testing.T) {
+	b, _ := bencode.Decode([]byte("d3:abci1ee"))
+	// FNV-1a 64 of "d3:abci1ee"
+	if h := bencode.Hash(b); h != 0xf509b09da62010c6 {
+		t.Fatalf("Expected hash %x, got %x", uint64(0xf509b09da62010c6), h)
+	}
+}
@@ -0,0 +1,258 @@
+package bencode
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Handler receives the elements of a document from DecodeEvents in order.
+// Each call gets the path of the element and the offset of its first byte;
+// End gets the path and the offset of the 'e' closing the list or dict. The
+// path is reused between calls, so clone it to keep it.
+//
+// An error returned by a method stops decoding and is returned by
+// DecodeEvents, except SkipValue.
+type Handler interface {
+	StartDict(p Path, offset int) error
+	// Key is called before each value of a dict, with the path of the dict.
+	Key(p Path, key string, offset int) error
+	StartList(p Path, offset int) error
+	Int(p Path, v int, offset int) error
+	String(p Path, s string, offset int) error
+	End(p Path, offset int) error
+}
+
+// SkipValue is returned by Handler.StartDict or StartList to skip the
+// contents of the dict or list, including its End, and by Key to skip the
+// value of the key. Skipped data is still checked, but strings in it are not
+// held in memory.
+var SkipValue = errors.New("skip value")
+
+// DecodeEvents reads a single element from r and reports it to h as it goes,
+// without building Belements. It accepts the same documents as Decode,
+// ignoring anything after the element, though r may have been read past
+// it, and returns a SyntaxError at the offset of the first invalid byte.
+func DecodeEvents(r io.Reader, h Handler) error {
+	d := eventDecoder{r: bufio.NewReader(r), h: h}
+	return d.decode()
+}
+
+type eventDecoder struct {
+	r      *bufio.Reader
+	h      Handler
+	offset int
+	path   Path
+}
+
+// eventFrame is a list or dict being read. They are kept on an explicit
+// stack rather than the call stack.
+type eventFrame struct {
+	dict  bool
+	skip  bool // contents are not reported
+	index int  // of the next list element
+	child bool // the path of a child is on d.path
+}
+
+// decode reads one element, reporting it and everything within it except
+// what the handler skips.
+func (d *eventDecoder) decode() error {
+	var stack []eventFrame
+	skip := false
+	for {
+		// read the element at d.path
+		start := d.offset
+		c, err := d.peek()
+		if err != nil {
+			return err
+		}
+		switch c {
+		case 'i':
+			d.discard(1)
+			b, err := d.until('e')
+			if err != nil {
+				return err
+			}
+			v, _, err := parseInt(b)
+			if err != nil {
+				return SyntaxError{Offset: start + 1, msg: err.Error()}
+			}
+			if !skip {
+				if err := d.h.Int(d.path, v, start); err != nil {
+					return err
+				}
+			}
+		case 'l', 'd':
+			if len(stack) == MaxDepth {
+				return SyntaxError{Offset: start, msg: fmt.Sprintf("Nesting deeper than %d", MaxDepth)}
+			}
+			d.discard(1)
+			if !skip {
+				if c == 'l' {
+					err = d.h.StartList(d.path, start)
+				} else {
+					err = d.h.StartDict(d.path, start)
+				}
+				if skip, err = d.handle(err); err != nil {
+					return err
+				}
+			}
+			stack = append(stack, eventFrame{dict: c == 'd', skip: skip})
+		default:
+			s, err := d.str(skip)
+			if err != nil {
+				return err
+			}
+			if !skip {
+				if err := d.h.String(d.path, s, start); err != nil {
+					return err
+				}
+			}
+		}
+
+		// move to the next element, ending the lists and dicts that are done
+		for {
+			if len(stack) == 0 {
+				return nil
+			}
+			top := &stack[len(stack)-1]
+			if top.child {
+				d.path = d.path[:len(d.path)-1]
+				top.child = false
+			}
+			if c, err = d.peek(); err != nil {
+				return err
+			}
+			if c != 'e' {
+				break
+			}
+			offset := d.offset
+			d.discard(1)
+			f := *top
+			stack = stack[:len(stack)-1]
+			if !f.skip {
+				if err := d.h.End(d.path, offset); err != nil {
+					return err
+				}
+			}
+		}
+
+		top := &stack[len(stack)-1]
+		skip = top.skip
+		if top.dict {
+			keyOffset := d.offset
+			key, err := d.str(top.skip)
+			if err != nil {
+				return err
+			}
+			if !top.skip {
+				if skip, err = d.handle(d.h.Key(d.path, key, keyOffset)); err != nil {
+					return err
+				}
+			}
+			if c, err = d.peek(); err != nil {
+				return err
+			}
+			if c == 'e' {
+				return SyntaxError{Offset: d.offset, msg: "Invalid dict format: missing value"}
+			}
+			d.path = append(d.path, PathElement{Key: key})
+		} else {
+			d.path = append(d.path, PathElement{Index: top.index, IsIndex: true})
+			top.index++
+		}
+		top.child = true
+	}
+}
+
+// handle turns SkipValue into skip.
+func (d *eventDecoder) handle(err error) (bool, error) {
+	if err == SkipValue {
+		return true, nil
+	}
+	return false, err
+}
+
+// str reads a string, leaving it out of memory when skip is set.
+func (d *eventDecoder) str(skip bool) (string, error) {
+	start := d.offset
+	b, err := d.until(':')
+	if _, ok := err.(SyntaxError); ok {
+		return "", SyntaxError{Offset: start, msg: "Invalid string format"}
+	}
+	if err != nil {
+		return "", err
+	}
+	n, _, err := parseLength(b)
+	if err != nil {
+		return "", SyntaxError{Offset: start, msg: err.Error()}
+	}
+
+	// the string grows as data arrives, so a bogus length fails with the
+	// data rather than with a huge allocation
+	w := io.Writer(io.Discard)
+	s := strings.Builder{}
+	if !skip {
+		w = &s
+	}
+	read, err := io.CopyN(w, d.r, int64(n))
+	d.offset += int(read)
+	if err == io.EOF {
+		return "", SyntaxError{Offset: start, msg: "Invalid string format. Length mismatch"}
+	}
+	return s.String(), err
+}
+
+// until reads up to and including c and returns the bytes before it, which
+// are the digits of a number.
+func (d *eventDecoder) until(c byte) ([]byte, error) {
+	var padded []byte // sign and a zero of a number padded past the buffer
+	for {
+		b, err := d.r.ReadSlice(c)
+		d.offset += len(b)
+		if err == io.EOF {
+			return nil, SyntaxError{Offset: d.offset, msg: fmt.Sprintf("Unexpected end of data, expected %q", c)}
+		}
+		if err == nil {
+			if padded != nil {
+				return append(padded, b[:len(b)-1]...), nil
+			}
+			return b[:len(b)-1], nil
+		}
+		if err != bufio.ErrBufferFull {
+			return nil, err
+		}
+
+		// only leading zeros, which Decode accepts, make a number this long
+		if padded == nil {
+			padded = []byte{}
+			if b[0] == '-' || b[0] == '+' {
+				padded, b = append(padded, b[0]), b[1:]
+			}
+			padded = append(padded, '0')
+		}
+		if len(bytes.TrimLeft(b, "0")) != 0 {
+			return nil, SyntaxError{Offset: d.offset, msg: fmt.Sprintf("Number too long, expected %q", c)}
+		}
+	}
+}
+
+func (d *eventDecoder) peek() (byte, error) {
+	b, err := d.r.Peek(1)
+	if err == io.EOF {
+		return 0, SyntaxError{Offset: d.offset, msg: "Unexpected end of data"}
+	}
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+// discard skips n bytes that have been peeked.
+func (d *eventDecoder) discard(n int) {
+	d.r.Discard(n)
+	d.offset += n
+}
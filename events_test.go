@@ -0,0 +1,134 @@
+package bencode_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/deathcrafter/bencode"
+)
+
+// recorder logs the events it receives and skips the values of the keys
+// and the contents of the dicts at the paths in skip.
+type recorder struct {
+	events []string
+	skip   map[string]bool
+}
+
+func (r *recorder) log(format string, args ...interface{}) {
+	r.events = append(r.events, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) StartDict(p bencode.Path, offset int) error {
+	r.log("%s d@%d", p, offset)
+	if r.skip["dict "+p.String()] {
+		return bencode.SkipValue
+	}
+	return nil
+}
+
+func (r *recorder) Key(p bencode.Path, key string, offset int) error {
+	r.log("%s k%s@%d", p, key, offset)
+	if r.skip["key "+p.Key(key).String()] {
+		return bencode.SkipValue
+	}
+	return nil
+}
+
+func (r *recorder) StartList(p bencode.Path, offset int) error {
+	r.log("%s l@%d", p, offset)
+	return nil
+}
+
+func (r *recorder) Int(p bencode.Path, v int, offset int) error {
+	r.log("%s i%d@%d", p, v, offset)
+	return nil
+}
+
+func (r *recorder) String(p bencode.Path, s string, offset int) error {
+	r.log("%s s%s@%d", p, s, offset)
+	if s == "stop" {
+		return errStop
+	}
+	return nil
+}
+
+func (r *recorder) End(p bencode.Path, offset int) error {
+	r.log("%s e@%d", p, offset)
+	return nil
+}
+
+var errStop = errors.New("stop")
+
+type nopHandler struct{}
+
+func (nopHandler) StartDict(bencode.Path, int) error      { return nil }
+func (nopHandler) Key(bencode.Path, string, int) error    { return nil }
+func (nopHandler) StartList(bencode.Path, int) error      { return nil }
+func (nopHandler) Int(bencode.Path, int, int) error       { return nil }
+func (nopHandler) String(bencode.Path, string, int) error { return nil }
+func (nopHandler) End(bencode.Path, int) error            { return nil }
+
+func TestDecodeEvents(t *testing.T) {
+	data := "d1:ali1e1:be1:cd1:x6:piecese1:di-2ee"
+	r := &recorder{}
+	if err := bencode.DecodeEvents(iotest.OneByteReader(strings.NewReader(data)), r); err != nil {
+		t.Fatal(err)
+	}
+	expected := ". d@0|. ka@1|.a l@4|.a[0] i1@5|.a[1] sb@8|.a e@11|" +
+		". kc@12|.c d@15|.c kx@16|.c.x spieces@19|.c e@27|. kd@28|.d i-2@31|. e@35"
+	if got := strings.Join(r.events, "|"); got != expected {
+		t.Fatalf("Expected %q, got %q", expected, got)
+	}
+
+	// skipping a key or a dict leaves out everything within
+	r = &recorder{skip: map[string]bool{"key .a": true, "dict .c": true}}
+	if err := bencode.DecodeEvents(strings.NewReader(data), r); err != nil {
+		t.Fatal(err)
+	}
+	expected = ". d@0|. ka@1|. kc@12|.c d@15|. kd@28|.d i-2@31|. e@35"
+	if got := strings.Join(r.events, "|"); got != expected {
+		t.Fatalf("Expected %q, got %q", expected, got)
+	}
+}
+
+func TestDecodeEventsErrors(t *testing.T) {
+	for _, c := range []struct {
+		data   string
+		offset int
+	}{
+		{"", 0},
+		{"li1e", 4},
+		{"i0x3e", 1},
+		{"d1:ae", 4},
+		{"5:abc", 0},
+		{"l-1:ae", 1},
+		// skipped values are still checked
+		{"d1:ai-xee", 5},
+	} {
+		r := &recorder{skip: map[string]bool{"key .a": true}}
+		err := bencode.DecodeEvents(strings.NewReader(c.data), r)
+		serr, ok := err.(bencode.SyntaxError)
+		if !ok || serr.Offset != c.offset {
+			t.Errorf("%q: expected syntax error at %d, got %v", c.data, c.offset, err)
+		}
+	}
+
+	// deep nesting is an error rather than a crash
+	deep := bytes.NewReader(bytes.Repeat([]byte("l"), 10<<20))
+	if err := bencode.DecodeEvents(deep, nopHandler{}); err == nil || !strings.Contains(err.Error(), "Nesting deeper than") {
+		t.Fatalf("Expected nesting error, got %v", err)
+	}
+
+	// handler errors stop decoding
+	r := &recorder{}
+	if err := bencode.DecodeEvents(strings.NewReader("l4:stop1:xe"), r); err != errStop {
+		t.Fatalf("Expected handler error, got %v", err)
+	}
+	if len(r.events) != 2 {
+		t.Fatalf("Decoding went on after error: %v", r.events)
+	}
+}
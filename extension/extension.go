@@ -0,0 +1,285 @@
+// Package extension encodes and decodes the payloads of the extension
+// protocol (BEP 10) and the ut_metadata extension (BEP 9). The payloads are
+// the bytes following the extended message id in a peer wire message.
+package extension
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"net/netip"
+
+	"github.com/deathcrafter/bencode"
+	"github.com/deathcrafter/bencode/internal/dictutil"
+)
+
+const (
+	HandshakeID = 0
+
+	UtMetadata = "ut_metadata"
+	UtPex      = "ut_pex"
+
+	MetadataPieceSize = 16 * 1024
+
+	// MaxMetadataSize bounds the metadata_size a peer may announce, as the
+	// metadata is allocated up front.
+	MaxMetadataSize = 32 * 1024 * 1024
+)
+
+const (
+	MetadataRequest = 0
+	MetadataData    = 1
+	MetadataReject  = 2
+)
+
+type Handshake struct {
+	M            map[string]int // extension name to message id, 0 disables
+	Port         int
+	Version      string
+	YourIP       netip.Addr
+	IPv4         netip.Addr
+	IPv6         netip.Addr
+	Reqq         int
+	MetadataSize int
+}
+
+type MetadataMessage struct {
+	Type      int
+	Piece     int
+	TotalSize int    // data messages only
+	Data      []byte // data messages only
+}
+
+func DecodeHandshake(data []byte) (Handshake, error) {
+	h := Handshake{}
+
+	b, err := bencode.Decode(data)
+	if err != nil {
+		return h, err
+	}
+	d, err := b.GetDict()
+	if err != nil {
+		return h, fmt.Errorf("extension: handshake: %w", err)
+	}
+
+	h.M = make(map[string]int)
+	if m, ok := d["m"]; ok {
+		md, err := m.GetDict()
+		if err != nil {
+			return h, fmt.Errorf("extension: m: %w", err)
+		}
+		for name, v := range md {
+			id, err := v.GetInt()
+			if err != nil {
+				return h, fmt.Errorf("extension: m: %s: %w", name, err)
+			}
+			if id < 0 || id > 255 {
+				return h, fmt.Errorf("extension: m: %s: id %d out of range", name, id)
+			}
+			h.M[name] = id
+		}
+	}
+
+	if h.Port, err = opt.Int(d, "p"); err != nil {
+		return h, err
+	}
+	if h.Version, err = opt.String(d, "v"); err != nil {
+		return h, err
+	}
+	if h.Reqq, err = opt.Int(d, "reqq"); err != nil {
+		return h, err
+	}
+	if h.MetadataSize, err = opt.Int(d, "metadata_size"); err != nil {
+		return h, err
+	}
+	if h.MetadataSize < 0 || h.MetadataSize > MaxMetadataSize {
+		return h, fmt.Errorf("extension: metadata_size %d out of range", h.MetadataSize)
+	}
+	if h.YourIP, err = optAddr(d, "yourip"); err != nil {
+		return h, err
+	}
+	if h.IPv4, err = optAddr(d, "ipv4"); err != nil {
+		return h, err
+	}
+	if h.IPv6, err = optAddr(d, "ipv6"); err != nil {
+		return h, err
+	}
+
+	return h, nil
+}
+
+func (h Handshake) Encode() ([]byte, error) {
+	m := make(map[string]interface{}, len(h.M))
+	for name, id := range h.M {
+		m[name] = id
+	}
+	d := map[string]interface{}{"m": m}
+
+	if h.Port != 0 {
+		d["p"] = h.Port
+	}
+	if h.Version != "" {
+		d["v"] = h.Version
+	}
+	if h.Reqq != 0 {
+		d["reqq"] = h.Reqq
+	}
+	if h.MetadataSize != 0 {
+		d["metadata_size"] = h.MetadataSize
+	}
+	if h.YourIP.IsValid() {
+		d["yourip"] = string(h.YourIP.Unmap().AsSlice())
+	}
+	if h.IPv4.IsValid() {
+		d["ipv4"] = string(h.IPv4.Unmap().AsSlice())
+	}
+	if h.IPv6.IsValid() {
+		d["ipv6"] = string(h.IPv6.AsSlice())
+	}
+
+	return bencode.EncodeDict(d)
+}
+
+func DecodeMetadataMessage(data []byte) (MetadataMessage, error) {
+	msg := MetadataMessage{}
+
+	b, rest, err := bencode.DecodePrefix(data)
+	if err != nil {
+		return msg, err
+	}
+
+	if msg.Type, err = b.GetDictInt("msg_type"); err != nil {
+		return msg, fmt.Errorf("extension: msg_type: %w", err)
+	}
+	if msg.Piece, err = b.GetDictInt("piece"); err != nil {
+		return msg, fmt.Errorf("extension: piece: %w", err)
+	}
+	if msg.Piece < 0 {
+		return msg, fmt.Errorf("extension: piece %d out of range", msg.Piece)
+	}
+
+	switch msg.Type {
+	case MetadataRequest, MetadataReject:
+		if len(rest) != 0 {
+			return msg, fmt.Errorf("extension: unexpected %d bytes after metadata message", len(rest))
+		}
+	case MetadataData:
+		if msg.TotalSize, err = b.GetDictInt("total_size"); err != nil {
+			return msg, fmt.Errorf("extension: total_size: %w", err)
+		}
+		if len(rest) > MetadataPieceSize {
+			return msg, fmt.Errorf("extension: metadata piece has %d bytes, maximum is %d", len(rest), MetadataPieceSize)
+		}
+		msg.Data = rest
+	default:
+		return msg, fmt.Errorf("extension: unknown msg_type %d", msg.Type)
+	}
+
+	return msg, nil
+}
+
+func (msg MetadataMessage) Encode() ([]byte, error) {
+	d := map[string]interface{}{
+		"msg_type": msg.Type,
+		"piece":    msg.Piece,
+	}
+	if msg.Type == MetadataData {
+		d["total_size"] = msg.TotalSize
+	}
+
+	ret, err := bencode.EncodeDict(d)
+	if err != nil {
+		return nil, err
+	}
+	return append(ret, msg.Data...), nil
+}
+
+// Metadata collects the pieces of an info dictionary fetched with
+// ut_metadata.
+type Metadata struct {
+	data     []byte
+	received []bool
+	missing  int
+}
+
+func NewMetadata(size int) (*Metadata, error) {
+	if size <= 0 || size > MaxMetadataSize {
+		return nil, fmt.Errorf("extension: invalid metadata size %d", size)
+	}
+	pieces := (size + MetadataPieceSize - 1) / MetadataPieceSize
+	return &Metadata{
+		data:     make([]byte, size),
+		received: make([]bool, pieces),
+		missing:  pieces,
+	}, nil
+}
+
+func (m *Metadata) Pieces() int {
+	return len(m.received)
+}
+
+func (m *Metadata) Complete() bool {
+	return m.missing == 0
+}
+
+// Missing returns the indices of the pieces that still have to be requested.
+func (m *Metadata) Missing() []int {
+	ret := make([]int, 0, m.missing)
+	for i, ok := range m.received {
+		if !ok {
+			ret = append(ret, i)
+		}
+	}
+	return ret
+}
+
+func (m *Metadata) AddPiece(msg MetadataMessage) error {
+	if msg.Type != MetadataData {
+		return fmt.Errorf("extension: msg_type %d is not a data message", msg.Type)
+	}
+	if msg.TotalSize != len(m.data) {
+		return fmt.Errorf("extension: total_size %d, expected %d", msg.TotalSize, len(m.data))
+	}
+	if msg.Piece < 0 || msg.Piece >= len(m.received) {
+		return fmt.Errorf("extension: piece %d out of range", msg.Piece)
+	}
+
+	start := msg.Piece * MetadataPieceSize
+	end := min(start+MetadataPieceSize, len(m.data))
+	if len(msg.Data) != end-start {
+		return fmt.Errorf("extension: piece %d has %d bytes, expected %d", msg.Piece, len(msg.Data), end-start)
+	}
+
+	copy(m.data[start:end], msg.Data)
+	if !m.received[msg.Piece] {
+		m.received[msg.Piece] = true
+		m.missing--
+	}
+	return nil
+}
+
+// Verify checks the assembled metadata against the torrent's info hash and
+// returns the raw info dictionary.
+func (m *Metadata) Verify(infoHash [20]byte) ([]byte, error) {
+	if !m.Complete() {
+		return nil, fmt.Errorf("extension: metadata is missing %d pieces", m.missing)
+	}
+	if sha1.Sum(m.data) != infoHash {
+		return nil, fmt.Errorf("extension: metadata does not match info hash %x", infoHash)
+	}
+	return m.data, nil
+}
+
+func optAddr(d map[string]bencode.Belement, key string) (netip.Addr, error) {
+	s, err := opt.String(d, key)
+	if err != nil || s == "" {
+		return netip.Addr{}, err
+	}
+	addr, ok := netip.AddrFromSlice([]byte(s))
+	if !ok {
+		return netip.Addr{}, fmt.Errorf("extension: %s has length %d, expected 4 or 16", key, len(s))
+	}
+	return addr, nil
+}
+
+// opt reads the optional keys of a dict.
+var opt = dictutil.Reader("extension")
@@ -0,0 +1,145 @@
+package extension_test
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"net/netip"
+	"testing"
+
+	"github.com/deathcrafter/bencode/extension"
+)
+
+func TestHandshake(t *testing.T) {
+	h := extension.Handshake{
+		M:            map[string]int{extension.UtMetadata: 3, extension.UtPex: 1},
+		Port:         6881,
+		Version:      "test 1.0",
+		YourIP:       netip.MustParseAddr("10.0.0.1"),
+		Reqq:         250,
+		MetadataSize: 31235,
+	}
+	e, err := h.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "d1:md11:ut_metadatai3e6:ut_pexi1ee13:metadata_sizei31235e1:pi6881e4:reqqi250e1:v8:test 1.06:yourip4:\x0a\x00\x00\x01e"
+	if string(e) != expected {
+		t.Fatalf("Expected %q, got %q", expected, string(e))
+	}
+
+	d, err := extension.DecodeHandshake(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.M[extension.UtMetadata] != 3 || d.Port != 6881 || d.YourIP != h.YourIP || d.MetadataSize != h.MetadataSize {
+		t.Fatalf("Expected %+v, got %+v", h, d)
+	}
+}
+
+func TestHandshakeInvalid(t *testing.T) {
+	for _, data := range []string{
+		"le",
+		"d1:mi1ee",
+		"d1:md11:ut_metadatai256eee",
+		"d6:yourip3:abce",
+		"d13:metadata_sizei-1ee",
+		"d1:md11:ut_metadatai3ee13:metadata_sizei4611686018427387904ee",
+	} {
+		if _, err := extension.DecodeHandshake([]byte(data)); err == nil {
+			t.Fatalf("Expected error for %q, got nil", data)
+		} else {
+			t.Log(err)
+		}
+	}
+}
+
+func TestMetadataData(t *testing.T) {
+	data := []byte("d8:msg_typei1e5:piecei0e10:total_sizei8eeABCDEFGH")
+	msg, err := extension.DecodeMetadataMessage(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if msg.Type != extension.MetadataData || msg.TotalSize != 8 || string(msg.Data) != "ABCDEFGH" {
+		t.Fatalf("Unexpected message %+v", msg)
+	}
+
+	e, err := msg.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(e, data) {
+		t.Fatalf("Expected %s, got %s", data, e)
+	}
+}
+
+func TestMetadataRequestTrailingData(t *testing.T) {
+	_, err := extension.DecodeMetadataMessage([]byte("d8:msg_typei0e5:piecei0eeXX"))
+	if err == nil {
+		t.Fatal("Expected error, got nil")
+	}
+	t.Log(err)
+}
+
+func TestMetadataAssemble(t *testing.T) {
+	info := bytes.Repeat([]byte("x"), extension.MetadataPieceSize+10)
+	hash := sha1.Sum(info)
+
+	m, err := extension.NewMetadata(len(info))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Pieces() != 2 {
+		t.Fatalf("Expected %d pieces, got %d", 2, m.Pieces())
+	}
+
+	if _, err := m.Verify(hash); err == nil {
+		t.Fatal("Expected error for incomplete metadata, got nil")
+	}
+
+	for _, piece := range []int{1, 0} {
+		start := piece * extension.MetadataPieceSize
+		end := min(start+extension.MetadataPieceSize, len(info))
+		err := m.AddPiece(extension.MetadataMessage{
+			Type:      extension.MetadataData,
+			Piece:     piece,
+			TotalSize: len(info),
+			Data:      info[start:end],
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := m.Verify(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, info) {
+		t.Fatal("Assembled metadata does not match")
+	}
+
+	if _, err := m.Verify([20]byte{}); err == nil {
+		t.Fatal("Expected error for wrong hash, got nil")
+	}
+}
+
+func TestMetadataOutOfRange(t *testing.T) {
+	for _, size := range []int{0, -1, extension.MaxMetadataSize + 1, 1 << 62} {
+		if _, err := extension.NewMetadata(size); err == nil {
+			t.Fatalf("Expected error for size %d, got nil", size)
+		}
+	}
+
+	m, err := extension.NewMetadata(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, piece := range []int{-1, 1} {
+		err := m.AddPiece(extension.MetadataMessage{Type: extension.MetadataData, Piece: piece, TotalSize: 10, Data: make([]byte, 10)})
+		if err == nil {
+			t.Fatalf("Expected error for piece %d, got nil", piece)
+		}
+	}
+}
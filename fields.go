@@ -0,0 +1,231 @@
+package bencode
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// field is a struct field mapped to a dict key. Tags have the form
+//
+//	`bencode:"key,omitempty,required,inline,default=value"`
+//
+// where every part is optional and default must come last since its value
+// runs to the end of the tag. A tag of "-" skips the field. Embedded structs
+// and pointers to structs without a key are inlined like fields tagged
+// inline. As in encoding/json, an inlined pointer is allocated when one of
+// its fields is unmarshalled or set to its default, its keys are left out
+// when marshalling while it is nil, and an embedded pointer to an unexported
+// struct type is ignored since it cannot be allocated.
+//
+// A map[string]Belement field tagged `bencode:",extra"` collects the keys
+// that match no other field when unmarshalling and is merged back into the
+// dict when marshalling.
+type field struct {
+	name      string // Go field path, for error messages
+	key       string
+	prefix    string // key as encoded, written before the value
+	index     []int
+	omitEmpty bool
+	required  bool
+	def       reflect.Value // invalid when there is no default
+}
+
+type structFields struct {
+	list  []field // in canonical key order
+	extra *field  // the extra field, if any
+}
+
+func (s structFields) byKey(key string) (field, bool) {
+	i, ok := slices.BinarySearchFunc(s.list, key, func(f field, key string) int {
+		return CompareKeys(f.key, key)
+	})
+	if !ok {
+		return field{}, false
+	}
+	return s.list[i], true
+}
+
+var belementMapType = reflect.TypeOf(map[string]Belement{})
+
+func typeFields(t reflect.Type) (structFields, error) {
+	fields := structFields{list: make([]field, 0, t.NumField())}
+	if err := collectFields(t, nil, "", []reflect.Type{t}, &fields); err != nil {
+		return fields, err
+	}
+
+	// struct fields are written in key order, not declaration order
+	slices.SortFunc(fields.list, func(a, b field) int {
+		return CompareKeys(a.key, b.key)
+	})
+	for i := range fields.list {
+		fields.list[i].prefix = string(AppendString(nil, fields.list[i].key))
+		if i > 0 && fields.list[i].key == fields.list[i-1].key {
+			return fields, BencodeError{msg: fmt.Sprintf("Duplicate key %q in %s: fields %s and %s", fields.list[i].key, t, fields.list[i-1].name, fields.list[i].name)}
+		}
+	}
+	return fields, nil
+}
+
+// collectFields adds the fields of t to fields. parents are the structs
+// being collected, t included, which an inlined pointer must not lead back to.
+func collectFields(t reflect.Type, index []int, prefix string, parents []reflect.Type, fields *structFields) error {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("bencode")
+		if tag == "-" {
+			continue
+		}
+		if !sf.IsExported() && !sf.Anonymous {
+			continue
+		}
+
+		f := field{
+			name:  prefix + sf.Name,
+			index: append(index[:len(index):len(index)], i),
+		}
+		key, opts := tag, ""
+		if comma := strings.IndexByte(tag, ','); comma != -1 {
+			key, opts = tag[:comma], tag[comma+1:]
+		}
+		st, isPtr := sf.Type, false
+		if st.Kind() == reflect.Pointer {
+			st, isPtr = st.Elem(), true
+		}
+		if sf.Anonymous && isPtr && !sf.IsExported() && st.Kind() == reflect.Struct {
+			continue
+		}
+		inline := sf.Anonymous && key == "" && st.Kind() == reflect.Struct
+		extra := false
+		if key == "" {
+			key = sf.Name
+		}
+
+		for opts != "" {
+			opt := opts
+			if strings.HasPrefix(opts, "default=") {
+				opts = ""
+			} else if comma := strings.IndexByte(opts, ','); comma != -1 {
+				opt, opts = opts[:comma], opts[comma+1:]
+			} else {
+				opts = ""
+			}
+
+			switch {
+			case opt == "omitempty":
+				f.omitEmpty = true
+			case opt == "required":
+				f.required = true
+			case opt == "inline":
+				inline = true
+			case opt == "extra":
+				extra = true
+			case strings.HasPrefix(opt, "default="):
+				def, err := parseDefault(sf.Type, opt[len("default="):])
+				if err != nil {
+					return BencodeError{msg: fmt.Sprintf("Invalid default for field %s: %s", f.name, err.Error())}
+				}
+				f.def = def
+			default:
+				return BencodeError{msg: fmt.Sprintf("Unknown option %q for field %s", opt, f.name)}
+			}
+		}
+
+		if inline {
+			if st.Kind() != reflect.Struct {
+				return BencodeError{msg: fmt.Sprintf("Cannot inline field %s of type %s", f.name, sf.Type)}
+			}
+			if slices.Contains(parents, st) {
+				return BencodeError{msg: fmt.Sprintf("Cannot inline field %s: %s contains itself", f.name, st)}
+			}
+			if err := collectFields(st, f.index, f.name+".", append(parents[:len(parents):len(parents)], st), fields); err != nil {
+				return err
+			}
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+
+		if extra {
+			if sf.Type != belementMapType {
+				return BencodeError{msg: fmt.Sprintf("Extra field %s must be map[string]bencode.Belement, got %s", f.name, sf.Type)}
+			}
+			if fields.extra != nil {
+				return BencodeError{msg: fmt.Sprintf("Fields %s and %s are both tagged extra", fields.extra.name, f.name)}
+			}
+			fields.extra = &f
+			continue
+		}
+
+		f.key = key
+		fields.list = append(fields.list, f)
+	}
+	return nil
+}
+
+// fieldByIndex returns the field of struct v at index, or false if an inlined
+// pointer on the way is nil.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// allocFieldByIndex returns the field of struct v at index, allocating the
+// nil inlined pointers on the way.
+func allocFieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+func parseDefault(t reflect.Type, s string) (reflect.Value, error) {
+	v := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return v, err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, t.Bits())
+		if err != nil {
+			return v, err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i, err := strconv.ParseUint(s, 10, t.Bits())
+		if err != nil {
+			return v, err
+		}
+		v.SetUint(i)
+	case reflect.Slice:
+		if t.Elem().Kind() != reflect.Uint8 {
+			return v, BencodeError{msg: fmt.Sprintf("type %s has no default", t)}
+		}
+		v.SetBytes([]byte(s))
+	default:
+		return v, BencodeError{msg: fmt.Sprintf("type %s has no default", t)}
+	}
+	return v, nil
+}
@@ -0,0 +1,49 @@
+package bencode
+
+import "os"
+
+// FileOptions configure DecodeFile.
+type FileOptions struct {
+	// Lazy leaves lists and dicts to be parsed when first read, as
+	// DecodeLazy does.
+	Lazy bool
+}
+
+// File is a document decoded by DecodeFile. Its elements, and the strings
+// and dict keys taken from them, refer to the mapped file rather than to
+// copies, so none of them may be used after Close. Use strings.Clone or
+// Belement.Encode to keep a value.
+type File struct {
+	Root Belement
+	data []byte
+}
+
+// DecodeFile decodes the document in the file at path, checking it as
+// DecodeLazy does. The file is memory mapped where the platform supports it,
+// so decoding does not read it into the heap, and stays mapped until Close.
+// The file must not be changed while it is mapped.
+func DecodeFile(path string, opts FileOptions) (*File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data, err := mapFile(f)
+	if err != nil {
+		return nil, err
+	}
+	root, err := decodeIndexed(&lazyIndex{data: data, noCopy: true}, opts.Lazy)
+	if err != nil {
+		unmapFile(data)
+		return nil, err
+	}
+	return &File{Root: root, data: data}, nil
+}
+
+// Close releases the mapping of the file. It is safe to call more than once.
+func (f *File) Close() error {
+	data := f.data
+	f.Root, f.data = InvalidBelement, nil
+	return unmapFile(data)
+}
@@ -0,0 +1,17 @@
+//go:build !linux
+
+package bencode
+
+import (
+	"io"
+	"os"
+)
+
+// mapFile reads the file instead where it is not mapped.
+func mapFile(f *os.File) ([]byte, error) {
+	return io.ReadAll(f)
+}
+
+func unmapFile(data []byte) error {
+	return nil
+}
@@ -0,0 +1,64 @@
+package bencode_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/deathcrafter/bencode"
+)
+
+func writeTemp(t *testing.T, data []byte) string {
+	path := filepath.Join(t.TempDir(), "test.torrent")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDecodeFile(t *testing.T) {
+	data := largeTorrent(t, 20)
+	path := writeTemp(t, data)
+	eager, err := bencode.Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, lazy := range []bool{false, true} {
+		f, err := bencode.DecodeFile(path, bencode.FileOptions{Lazy: lazy})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bencode.Equal(f.Root, eager) {
+			t.Errorf("Lazy %v: decoded file differs", lazy)
+		}
+		name, err := f.Root.Get(bencode.Path{}.Key("info").Key("name"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, _ := name.GetString()
+		s = strings.Clone(s)
+
+		if err := f.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err := f.Close(); err != nil {
+			t.Fatalf("Second Close: %v", err)
+		}
+		if s != "example" || f.Root.Type != bencode.TypeInvalid {
+			t.Fatalf("Unexpected name %q or root %v after Close", s, f.Root)
+		}
+	}
+}
+
+func TestDecodeFileErrors(t *testing.T) {
+	if _, err := bencode.DecodeFile(filepath.Join(t.TempDir(), "missing"), bencode.FileOptions{}); !os.IsNotExist(err) {
+		t.Fatalf("Expected not exist error, got %v", err)
+	}
+	for _, data := range []string{"", "d1:ae", "i1.5e"} {
+		if _, err := bencode.DecodeFile(writeTemp(t, []byte(data)), bencode.FileOptions{}); err == nil {
+			t.Errorf("Expected error for %q", data)
+		}
+	}
+}
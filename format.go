@@ -0,0 +1,155 @@
+package bencode
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type PrintOptions struct {
+	Indent   string // defaults to two spaces
+	MaxDepth int    // containers nested deeper are collapsed, 0 means no limit
+	MaxWidth int    // strings are cut after this many characters or hex digits, 0 means no limit
+}
+
+var DefaultPrintOptions = PrintOptions{Indent: "  ", MaxWidth: 80}
+
+// Sprint renders v as an indented tree. Text strings are quoted, binary
+// strings are shown as their length followed by hex.
+func (o PrintOptions) Sprint(v Belement) string {
+	if o.Indent == "" {
+		o.Indent = "  "
+	}
+	b := strings.Builder{}
+	o.write(&b, v, 0)
+	return b.String()
+}
+
+func (o PrintOptions) write(b *strings.Builder, v Belement, depth int) {
+	switch v.Type {
+	case TypeInt:
+		i, _ := v.GetInt()
+		b.WriteString(strconv.Itoa(i))
+	case TypeString:
+		s, _ := v.GetString()
+		b.WriteString(o.formatString(s))
+	case TypeList:
+		l, _ := v.GetList()
+		fmt.Fprintf(b, "list (%d)", len(l))
+		if len(l) > 0 && o.MaxDepth > 0 && depth >= o.MaxDepth {
+			b.WriteString(" …")
+			return
+		}
+		for i, e := range l {
+			b.WriteString("\n" + strings.Repeat(o.Indent, depth+1) + "[" + strconv.Itoa(i) + "] ")
+			o.write(b, e, depth+1)
+		}
+	case TypeDict:
+		d, _ := v.GetDict()
+		fmt.Fprintf(b, "dict (%d)", len(d))
+		if len(d) > 0 && o.MaxDepth > 0 && depth >= o.MaxDepth {
+			b.WriteString(" …")
+			return
+		}
+		for _, k := range sortedKeys(d) {
+			b.WriteString("\n" + strings.Repeat(o.Indent, depth+1) + o.formatString(k) + ": ")
+			o.write(b, d[k], depth+1)
+		}
+	default:
+		b.WriteString(v.Type.String())
+	}
+}
+
+func (o PrintOptions) formatString(s string) string {
+	if isText(s) {
+		if o.MaxWidth > 0 && utf8.RuneCountInString(s) > o.MaxWidth {
+			cut := 0
+			for i := 0; i < o.MaxWidth; i++ {
+				_, size := utf8.DecodeRuneInString(s[cut:])
+				cut += size
+			}
+			return fmt.Sprintf("%s… (%d bytes)", strconv.Quote(s[:cut]), len(s))
+		}
+		return strconv.Quote(s)
+	}
+
+	h := fmt.Sprintf("%x", s)
+	if o.MaxWidth > 0 && len(h) > o.MaxWidth {
+		h = h[:o.MaxWidth] + "…"
+	}
+	return fmt.Sprintf("<%d bytes> %s", len(s), h)
+}
+
+func isText(s string) bool {
+	for _, r := range s {
+		if r == utf8.RuneError || !unicode.IsPrint(r) && r != '\n' && r != '\t' && r != '\r' {
+			return false
+		}
+	}
+	return true
+}
+
+func (v Belement) String() string {
+	return DefaultPrintOptions.Sprint(v)
+}
+
+// Format implements fmt.Formatter. %v and %s print the tree of String, with
+// the width setting MaxWidth and the precision MaxDepth, e.g. %40.2v.
+// %#v prints Go source constructing v.
+func (v Belement) Format(f fmt.State, verb rune) {
+	switch verb {
+	case 'v', 's':
+		if verb == 'v' && f.Flag('#') {
+			b := strings.Builder{}
+			writeGoSyntax(&b, v)
+			fmt.Fprint(f, b.String())
+			return
+		}
+		o := DefaultPrintOptions
+		if w, ok := f.Width(); ok {
+			o.MaxWidth = w
+		}
+		if p, ok := f.Precision(); ok {
+			o.MaxDepth = p
+		}
+		fmt.Fprint(f, o.Sprint(v))
+	default:
+		fmt.Fprintf(f, "%%!%c(bencode.Belement=%s)", verb, v.Type)
+	}
+}
+
+func writeGoSyntax(b *strings.Builder, v Belement) {
+	switch v.Type {
+	case TypeInt:
+		i, _ := v.GetInt()
+		fmt.Fprintf(b, "bencode.Belement{Type: bencode.TypeInt, Value: %d}", i)
+	case TypeString:
+		s, _ := v.GetString()
+		fmt.Fprintf(b, "bencode.Belement{Type: bencode.TypeString, Value: %s}", strconv.Quote(s))
+	case TypeList:
+		l, _ := v.GetList()
+		b.WriteString("bencode.Belement{Type: bencode.TypeList, Value: []bencode.Belement{")
+		for i, e := range l {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			writeGoSyntax(b, e)
+		}
+		b.WriteString("}}")
+	case TypeDict:
+		d, _ := v.GetDict()
+		b.WriteString("bencode.Belement{Type: bencode.TypeDict, Value: map[string]bencode.Belement{")
+		for i, k := range sortedKeys(d) {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(strconv.Quote(k) + ": ")
+			writeGoSyntax(b, d[k])
+		}
+		b.WriteString("}}")
+	default:
+		b.WriteString("bencode.InvalidBelement")
+	}
+}
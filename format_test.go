@@ -0,0 +1,67 @@
+package bencode_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/deathcrafter/bencode"
+)
+
+func TestFormatTree(t *testing.T) {
+	b, err := bencode.Decode([]byte("d4:infod4:name5:a.txt6:pieces4:\x00\x01\x02\x03e4:listli1eleee"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `dict (2)
+  "info": dict (2)
+    "name": "a.txt"
+    "pieces": <4 bytes> 00010203
+  "list": list (2)
+    [0] 1
+    [1] list (0)`
+	if b.String() != expected {
+		t.Fatalf("Expected\n%s\ngot\n%s", expected, b.String())
+	}
+	if fmt.Sprintf("%v", b) != expected {
+		t.Fatalf("Expected\n%s\ngot\n%v", expected, b)
+	}
+
+	expected = `dict (2)
+  "info": dict (2) …
+  "list": list (2) …`
+	if s := fmt.Sprintf("%.1v", b); s != expected {
+		t.Fatalf("Expected\n%s\ngot\n%s", expected, s)
+	}
+}
+
+func TestFormatWidth(t *testing.T) {
+	b := bencode.Belement{Type: bencode.TypeList, Value: []bencode.Belement{
+		{Type: bencode.TypeString, Value: "abcdefghij"},
+		{Type: bencode.TypeString, Value: "\xff\xfe\xfd\xfc"},
+	}}
+
+	expected := `list (2)
+  [0] "abcd"… (10 bytes)
+  [1] <4 bytes> fffe…`
+	if s := fmt.Sprintf("%4v", b); s != expected {
+		t.Fatalf("Expected\n%s\ngot\n%s", expected, s)
+	}
+
+	s := bencode.PrintOptions{Indent: "\t"}.Sprint(b)
+	if s != "list (2)\n\t[0] \"abcdefghij\"\n\t[1] <4 bytes> fffefdfc" {
+		t.Fatalf("Unexpected output %q", s)
+	}
+}
+
+func TestFormatGoSyntax(t *testing.T) {
+	b, err := bencode.Decode([]byte("d1:ali1e1:xee"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `bencode.Belement{Type: bencode.TypeDict, Value: map[string]bencode.Belement{"a": bencode.Belement{Type: bencode.TypeList, Value: []bencode.Belement{bencode.Belement{Type: bencode.TypeInt, Value: 1}, bencode.Belement{Type: bencode.TypeString, Value: "x"}}}}}`
+	if s := fmt.Sprintf("%#v", b); s != expected {
+		t.Fatalf("Expected\n%s\ngot\n%s", expected, s)
+	}
+}
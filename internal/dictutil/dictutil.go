@@ -0,0 +1,57 @@
+// Package dictutil reads optional keys of decoded dicts for the protocol
+// packages of the module.
+package dictutil
+
+import (
+	"fmt"
+
+	"github.com/deathcrafter/bencode"
+)
+
+// Reader reads optional keys. Its errors name the key, prefixed with the
+// Reader itself, which is usually the name of the package using it. The
+// zero Reader names only the key, for callers that add their own context.
+type Reader string
+
+func (r Reader) errorf(key string, err error) error {
+	if r == "" {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	return fmt.Errorf("%s: %s: %w", r, key, err)
+}
+
+// Int returns the int at key, or 0 when d does not have key.
+func (r Reader) Int(d map[string]bencode.Belement, key string) (int, error) {
+	p, err := r.IntPtr(d, key)
+	if p == nil {
+		return 0, err
+	}
+	return *p, nil
+}
+
+// IntPtr returns the int at key, or nil when d does not have key, for
+// values where 0 differs from absent.
+func (r Reader) IntPtr(d map[string]bencode.Belement, key string) (*int, error) {
+	v, ok := d[key]
+	if !ok {
+		return nil, nil
+	}
+	i, err := v.GetInt()
+	if err != nil {
+		return nil, r.errorf(key, err)
+	}
+	return &i, nil
+}
+
+// String returns the string at key, or "" when d does not have key.
+func (r Reader) String(d map[string]bencode.Belement, key string) (string, error) {
+	v, ok := d[key]
+	if !ok {
+		return "", nil
+	}
+	s, err := v.GetString()
+	if err != nil {
+		return "", r.errorf(key, err)
+	}
+	return s, nil
+}
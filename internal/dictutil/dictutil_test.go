@@ -0,0 +1,39 @@
+package dictutil_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/deathcrafter/bencode"
+	"github.com/deathcrafter/bencode/internal/dictutil"
+)
+
+func TestReader(t *testing.T) {
+	b, err := bencode.Decode([]byte("d1:ai0e1:b1:xe"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, _ := b.GetDict()
+	opt := dictutil.Reader("test")
+
+	if p, err := opt.IntPtr(d, "a"); err != nil || p == nil || *p != 0 {
+		t.Fatalf("Expected 0, got %v, %v", p, err)
+	}
+	if p, err := opt.IntPtr(d, "c"); err != nil || p != nil {
+		t.Fatalf("Expected nil, got %v, %v", p, err)
+	}
+	if s, err := opt.String(d, "b"); err != nil || s != "x" {
+		t.Fatalf("Expected x, got %q, %v", s, err)
+	}
+	if s, err := opt.String(d, "c"); err != nil || s != "" {
+		t.Fatalf("Expected empty string, got %q, %v", s, err)
+	}
+
+	_, err = opt.Int(d, "b")
+	if err == nil || !strings.HasPrefix(err.Error(), "test: b: ") {
+		t.Fatalf("Expected error naming the key, got %v", err)
+	}
+	if _, err = dictutil.Reader("").String(d, "a"); err == nil || !strings.HasPrefix(err.Error(), "a: ") {
+		t.Fatalf("Expected error naming the key, got %v", err)
+	}
+}
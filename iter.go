@@ -0,0 +1,67 @@
+package bencode
+
+import "iter"
+
+// Items returns an iterator over the keys and values of a dict in canonical
+// key order. It yields nothing for other elements.
+func (v Belement) Items() iter.Seq2[string, Belement] {
+	return func(yield func(string, Belement) bool) {
+		d, err := v.GetDict()
+		if err != nil {
+			return
+		}
+		for _, k := range sortedKeys(d) {
+			if !yield(k, d[k]) {
+				return
+			}
+		}
+	}
+}
+
+// Elements returns an iterator over the indices and values of a list. It
+// yields nothing for other elements.
+func (v Belement) Elements() iter.Seq2[int, Belement] {
+	return func(yield func(int, Belement) bool) {
+		l, err := v.GetList()
+		if err != nil {
+			return
+		}
+		for i, e := range l {
+			if !yield(i, e) {
+				return
+			}
+		}
+	}
+}
+
+// Walk returns an iterator over v and every element within it, each with its
+// path from v. Parents come before their children, and the children of a
+// dict in canonical key order. Each path yielded is a fresh copy that may be
+// kept.
+func (v Belement) Walk() iter.Seq2[Path, Belement] {
+	return func(yield func(Path, Belement) bool) {
+		v.walk(Path{}, yield)
+	}
+}
+
+// walk reports whether the iteration should go on.
+func (v Belement) walk(p Path, yield func(Path, Belement) bool) bool {
+	if !yield(p, v) {
+		return false
+	}
+	switch v.Type {
+	case TypeList:
+		for i, e := range v.Elements() {
+			if !e.walk(p.Index(i), yield) {
+				return false
+			}
+		}
+	case TypeDict:
+		for k, e := range v.Items() {
+			if !e.walk(p.Key(k), yield) {
+				return false
+			}
+		}
+	}
+	return true
+}
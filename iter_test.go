@@ -0,0 +1,78 @@
+package bencode_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/deathcrafter/bencode"
+)
+
+func TestItems(t *testing.T) {
+	b, err := bencode.Decode([]byte("d1:ci3e1:ai1e1:bi2ee"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := []string{}
+	for k, v := range b.Items() {
+		i, _ := v.GetInt()
+		keys = append(keys, k+strings.Repeat("+", i))
+	}
+	if strings.Join(keys, " ") != "a+ b++ c+++" {
+		t.Fatalf("Unexpected items %v", keys)
+	}
+
+	for k := range b.Items() {
+		if k != "a" {
+			t.Fatalf("Unexpected key %q", k)
+		}
+		break
+	}
+	for range bencode.InvalidBelement.Items() {
+		t.Fatal("Invalid element has items")
+	}
+}
+
+func TestElements(t *testing.T) {
+	b, err := bencode.DecodeLazy([]byte("l1:a1:b1:ce"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := ""
+	for i, v := range b.Elements() {
+		s, _ := v.GetString()
+		got += strings.Repeat(s, i+1)
+	}
+	if got != "abbccc" {
+		t.Fatalf("Unexpected elements %q", got)
+	}
+	for range (bencode.Belement{Type: bencode.TypeInt, Value: 1}).Elements() {
+		t.Fatal("Int has elements")
+	}
+}
+
+func TestWalk(t *testing.T) {
+	b, err := bencode.Decode([]byte("d4:infod4:name1:xe8:announcel1:a1:bee"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := []string{}
+	for p, v := range b.Walk() {
+		paths = append(paths, p.String()+"="+v.Type.String())
+	}
+	expected := ".=dict .announce=list .announce[0]=string .announce[1]=string .info=dict .info.name=string"
+	if strings.Join(paths, " ") != expected {
+		t.Fatalf("Expected %q, got %q", expected, strings.Join(paths, " "))
+	}
+
+	// paths may be kept, and stopping early ends the walk
+	kept := []bencode.Path{}
+	for p := range b.Walk() {
+		kept = append(kept, p)
+		if len(kept) == 3 {
+			break
+		}
+	}
+	if len(kept) != 3 || kept[1].String() != ".announce" || kept[2].String() != ".announce[0]" {
+		t.Fatalf("Unexpected paths %v", kept)
+	}
+}
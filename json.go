@@ -0,0 +1,263 @@
+package bencode
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"unicode/utf8"
+)
+
+// JSON mapping used by ToJSON and FromJSON:
+//
+//	int within ±(2^53-1)     -> number
+//	other int                -> {"$int": "<decimal>"}
+//	UTF-8 string             -> string
+//	other string             -> {"$bytes": "<standard base64>"}
+//	list                     -> array
+//	dict                     -> object with keys in byte order
+//
+// A dict is written as {"$dict": [[key, value], ...]} instead of an object
+// when one of its keys is not valid UTF-8, or when it has a single key that
+// is itself "$bytes", "$int" or "$dict" and would otherwise be read back as a
+// tag. Keys in the pair form follow the string rules above.
+//
+// Larger ints are tagged because many JSON readers, such as JavaScript and
+// Go's interface{} decoding, hold numbers as float64 and would round them.
+const (
+	jsonBytesTag = "$bytes"
+	jsonIntTag   = "$int"
+	jsonDictTag  = "$dict"
+
+	maxJSONSafeInt = 1<<53 - 1
+)
+
+func ToJSON(v Belement) ([]byte, error) {
+	return appendJSON(make([]byte, 0), v)
+}
+
+func FromJSON(data []byte) (Belement, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var x interface{}
+	if err := dec.Decode(&x); err != nil {
+		return InvalidBelement, BencodeError{msg: fmt.Sprintf("Invalid JSON: %s", err.Error())}
+	}
+	if dec.More() {
+		return InvalidBelement, BencodeError{msg: "Invalid JSON: trailing data"}
+	}
+	return fromJSONValue(x)
+}
+
+func (v Belement) MarshalJSON() ([]byte, error) {
+	return ToJSON(v)
+}
+
+func (v *Belement) UnmarshalJSON(data []byte) error {
+	b, err := FromJSON(data)
+	if err != nil {
+		return err
+	}
+	*v = b
+	return nil
+}
+
+func appendJSON(dst []byte, v Belement) ([]byte, error) {
+	switch v.Type {
+	case TypeInt:
+		i, err := v.GetInt()
+		if err != nil {
+			return nil, err
+		}
+		if i > maxJSONSafeInt || i < -maxJSONSafeInt {
+			dst = append(dst, `{"$int":"`...)
+			dst = strconv.AppendInt(dst, int64(i), 10)
+			return append(dst, `"}`...), nil
+		}
+		return strconv.AppendInt(dst, int64(i), 10), nil
+	case TypeString:
+		s, err := v.GetString()
+		if err != nil {
+			return nil, err
+		}
+		return appendJSONString(dst, s), nil
+	case TypeList:
+		l, err := v.GetList()
+		if err != nil {
+			return nil, err
+		}
+		dst = append(dst, '[')
+		for i, e := range l {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			if dst, err = appendJSON(dst, e); err != nil {
+				return nil, err
+			}
+		}
+		return append(dst, ']'), nil
+	case TypeDict:
+		d, err := v.GetDict()
+		if err != nil {
+			return nil, err
+		}
+		keys := sortedKeys(d)
+		tagged := false
+		for _, k := range keys {
+			if !utf8.ValidString(k) {
+				tagged = true
+			}
+		}
+		if len(keys) == 1 && (keys[0] == jsonBytesTag || keys[0] == jsonIntTag || keys[0] == jsonDictTag) {
+			tagged = true
+		}
+
+		if tagged {
+			dst = append(dst, `{"$dict":[`...)
+			for i, k := range keys {
+				if i > 0 {
+					dst = append(dst, ',')
+				}
+				dst = append(dst, '[')
+				dst = appendJSONString(dst, k)
+				dst = append(dst, ',')
+				if dst, err = appendJSON(dst, d[k]); err != nil {
+					return nil, err
+				}
+				dst = append(dst, ']')
+			}
+			return append(dst, "]}"...), nil
+		}
+
+		dst = append(dst, '{')
+		for i, k := range keys {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = appendJSONString(dst, k)
+			dst = append(dst, ':')
+			if dst, err = appendJSON(dst, d[k]); err != nil {
+				return nil, err
+			}
+		}
+		return append(dst, '}'), nil
+	default:
+		return nil, BencodeError{msg: fmt.Sprintf("Cannot convert %s Belement to JSON", v.Type)}
+	}
+}
+
+func appendJSONString(dst []byte, s string) []byte {
+	if !utf8.ValidString(s) {
+		dst = append(dst, `{"$bytes":"`...)
+		dst = base64.StdEncoding.AppendEncode(dst, []byte(s))
+		return append(dst, `"}`...)
+	}
+
+	buf := bytes.Buffer{}
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s) // cannot fail for a valid UTF-8 string
+	return append(dst, bytes.TrimSuffix(buf.Bytes(), []byte{'\n'})...)
+}
+
+func fromJSONValue(x interface{}) (Belement, error) {
+	switch t := x.(type) {
+	case json.Number:
+		i, err := strconv.Atoi(t.String())
+		if err != nil {
+			return InvalidBelement, BencodeError{msg: fmt.Sprintf("Invalid JSON number %s: only integers are supported", t)}
+		}
+		return Belement{Type: TypeInt, Value: i}, nil
+	case string:
+		return Belement{Type: TypeString, Value: t}, nil
+	case []interface{}:
+		l := make([]Belement, 0, len(t))
+		for _, e := range t {
+			b, err := fromJSONValue(e)
+			if err != nil {
+				return InvalidBelement, err
+			}
+			l = append(l, b)
+		}
+		return Belement{Type: TypeList, Value: l}, nil
+	case map[string]interface{}:
+		if len(t) == 1 {
+			if tag, ok := t[jsonBytesTag]; ok {
+				s, err := fromJSONBytes(tag)
+				if err != nil {
+					return InvalidBelement, err
+				}
+				return Belement{Type: TypeString, Value: s}, nil
+			}
+			if tag, ok := t[jsonIntTag]; ok {
+				s, ok := tag.(string)
+				if !ok {
+					return InvalidBelement, BencodeError{msg: "Invalid $int value: expected decimal string"}
+				}
+				i, err := strconv.Atoi(s)
+				if err != nil {
+					return InvalidBelement, BencodeError{msg: fmt.Sprintf("Invalid $int value: %s", err.Error())}
+				}
+				return Belement{Type: TypeInt, Value: i}, nil
+			}
+			if tag, ok := t[jsonDictTag]; ok {
+				return fromJSONPairs(tag)
+			}
+		}
+		d := make(map[string]Belement, len(t))
+		for k, e := range t {
+			b, err := fromJSONValue(e)
+			if err != nil {
+				return InvalidBelement, err
+			}
+			d[k] = b
+		}
+		return Belement{Type: TypeDict, Value: d}, nil
+	default:
+		return InvalidBelement, BencodeError{msg: fmt.Sprintf("JSON value %v has no bencode equivalent", t)}
+	}
+}
+
+func fromJSONBytes(x interface{}) (string, error) {
+	s, ok := x.(string)
+	if !ok {
+		return "", BencodeError{msg: "Invalid $bytes value: expected base64 string"}
+	}
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return "", BencodeError{msg: fmt.Sprintf("Invalid $bytes value: %s", err.Error())}
+	}
+	return string(b), nil
+}
+
+func fromJSONPairs(x interface{}) (Belement, error) {
+	pairs, ok := x.([]interface{})
+	if !ok {
+		return InvalidBelement, BencodeError{msg: "Invalid $dict value: expected array of pairs"}
+	}
+
+	d := make(map[string]Belement, len(pairs))
+	for _, p := range pairs {
+		pair, ok := p.([]interface{})
+		if !ok || len(pair) != 2 {
+			return InvalidBelement, BencodeError{msg: "Invalid $dict value: expected [key, value] pair"}
+		}
+		k, err := fromJSONValue(pair[0])
+		if err != nil {
+			return InvalidBelement, err
+		}
+		key, err := k.GetString()
+		if err != nil {
+			return InvalidBelement, BencodeError{msg: fmt.Sprintf("Invalid $dict key: %s", err.Error())}
+		}
+		if _, ok := d[key]; ok {
+			return InvalidBelement, BencodeError{msg: fmt.Sprintf("Duplicate $dict key %q", key)}
+		}
+		if d[key], err = fromJSONValue(pair[1]); err != nil {
+			return InvalidBelement, err
+		}
+	}
+	return Belement{Type: TypeDict, Value: d}, nil
+}
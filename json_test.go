@@ -0,0 +1,144 @@
+package bencode_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/deathcrafter/bencode"
+)
+
+func TestToJSON(t *testing.T) {
+	b, err := bencode.Decode([]byte("d3:abci-12e3:bin2:\xff\x004:listl1:<i9223372036854775807eee"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	j, err := bencode.ToJSON(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"abc":-12,"bin":{"$bytes":"/wA="},"list":["<",{"$int":"9223372036854775807"}]}`
+	if string(j) != expected {
+		t.Fatalf("Expected %s, got %s", expected, string(j))
+	}
+
+	t.Logf("JSON: %s", string(j))
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	for _, data := range []string{
+		"i0e",
+		"0:",
+		"4:\x00\x01\x02\x03",
+		"le",
+		"de",
+		"d1:a1:b2:\xff\xfei1ee",
+		"d6:$bytes3:abce",
+		"d5:$dictlee",
+		"d6:$bytes3:abc1:xi1ee",
+		"ld5:$dictd6:$bytesi1eeee",
+		"i9007199254740991e",
+		"i9007199254740992e",
+		"i-9223372036854775808e",
+		"d4:$inti1ee",
+		"d4:$int3:abce",
+	} {
+		b, err := bencode.Decode([]byte(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		j, err := bencode.ToJSON(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		r, err := bencode.FromJSON(j)
+		if err != nil {
+			t.Fatalf("%s: %s", string(j), err)
+		}
+
+		e, err := r.Encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(e) != data {
+			t.Fatalf("Expected %q, got %q via %s", data, string(e), string(j))
+		}
+	}
+}
+
+func TestFromJSONInvalid(t *testing.T) {
+	for _, data := range []string{
+		`1.5`,
+		`true`,
+		`null`,
+		`{"$bytes":1}`,
+		`{"$bytes":"***"}`,
+		`{"$dict":[["a",1],["a",2]]}`,
+		`{"$dict":[[1,1]]}`,
+		`{"$int":1}`,
+		`{"$int":"1.5"}`,
+		`{"$int":"99999999999999999999"}`,
+		`[1] [2]`,
+	} {
+		if _, err := bencode.FromJSON([]byte(data)); err == nil {
+			t.Fatalf("Expected error for %s, got nil", data)
+		} else {
+			t.Log(err)
+		}
+	}
+}
+
+func TestBelementMarshalJSON(t *testing.T) {
+	x := struct {
+		Torrent bencode.Belement `json:"torrent"`
+	}{
+		Torrent: bencode.Belement{Type: bencode.TypeList, Value: []bencode.Belement{{Type: bencode.TypeInt, Value: 1}}},
+	}
+	j, err := json.Marshal(x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(j) != `{"torrent":[1]}` {
+		t.Fatalf("Expected %s, got %s", `{"torrent":[1]}`, string(j))
+	}
+
+	y := bencode.Belement{}
+	if err := json.Unmarshal([]byte(`{"a":"b"}`), &y); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := y.GetDictString("a"); s != "b" {
+		t.Fatalf("Expected %s, got %s", "b", s)
+	}
+}
+
+func TestJSONLargeInt(t *testing.T) {
+	for _, c := range []struct {
+		v        int
+		expected string
+	}{
+		{1<<53 - 1, `9007199254740991`},
+		{-(1<<53 - 1), `-9007199254740991`},
+		{1 << 53, `{"$int":"9007199254740992"}`},
+		{-1 << 53, `{"$int":"-9007199254740992"}`},
+	} {
+		j, err := bencode.ToJSON(bencode.Belement{Type: bencode.TypeInt, Value: c.v})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(j) != c.expected {
+			t.Fatalf("Expected %s, got %s", c.expected, string(j))
+		}
+
+		// every value in the output survives a reader that uses float64
+		var x interface{}
+		if err := json.Unmarshal(j, &x); err != nil {
+			t.Fatal(err)
+		}
+		if f, ok := x.(float64); ok && int(f) != c.v {
+			t.Fatalf("%s reads back as %v", string(j), f)
+		}
+	}
+}
@@ -0,0 +1,540 @@
+// Package krpc encodes and decodes the DHT KRPC protocol (BEP 5) including
+// the BEP 44 get and put extensions.
+//
+// Decode is meant for datagrams from untrusted peers: every key is type
+// checked and the method specific arguments are validated before a Message
+// is returned.
+package krpc
+
+import (
+	"fmt"
+	"net/netip"
+
+	"github.com/deathcrafter/bencode"
+	"github.com/deathcrafter/bencode/compact"
+	"github.com/deathcrafter/bencode/internal/dictutil"
+)
+
+const (
+	TypeQuery    = "q"
+	TypeResponse = "r"
+	TypeError    = "e"
+)
+
+const (
+	MethodPing         = "ping"
+	MethodFindNode     = "find_node"
+	MethodGetPeers     = "get_peers"
+	MethodAnnouncePeer = "announce_peer"
+	MethodGet          = "get"
+	MethodPut          = "put"
+)
+
+const (
+	ErrorGeneric       = 201
+	ErrorServer        = 202
+	ErrorProtocol      = 203
+	ErrorMethodUnknown = 204
+)
+
+const (
+	NodeInfoLen  = 20 + compact.PeerLen
+	NodeInfo6Len = 20 + compact.Peer6Len
+
+	// BEP 44 limits
+	MaxValueLen = 1000
+	MaxSaltLen  = 64
+	KeyLen      = 32
+	SigLen      = 64
+)
+
+type NodeID [20]byte
+
+type NodeInfo struct {
+	ID   NodeID
+	Addr netip.AddrPort
+}
+
+type Message struct {
+	TransactionID string
+	Type          string
+	Method        string // queries only
+	Version       string // optional "v" key
+	Args          Args   // queries only
+	Return        Return // responses only
+	Error         Error  // errors only
+}
+
+type Args struct {
+	ID          NodeID
+	Target      NodeID // find_node and get
+	InfoHash    NodeID // get_peers and announce_peer
+	Port        int
+	ImpliedPort bool
+	Token       string
+
+	// put
+	Value     bencode.Belement
+	Key       string
+	Signature string
+	Salt      string
+	Seq       *int
+	CAS       *int
+}
+
+type Return struct {
+	ID     NodeID
+	Nodes  []NodeInfo
+	Nodes6 []NodeInfo
+	Token  string
+	Values []netip.AddrPort
+
+	// get
+	Value     bencode.Belement
+	Key       string
+	Signature string
+	Seq       *int
+}
+
+type Error struct {
+	Code    int
+	Message string
+}
+
+func (e Error) Error() string {
+	return fmt.Sprintf("krpc: error %d: %s", e.Code, e.Message)
+}
+
+func Decode(data []byte) (Message, error) {
+	m := Message{}
+
+	b, err := bencode.Decode(data)
+	if err != nil {
+		return m, err
+	}
+	d, err := b.GetDict()
+	if err != nil {
+		return m, fmt.Errorf("krpc: message: %w", err)
+	}
+
+	if m.TransactionID, err = b.GetDictString("t"); err != nil {
+		return m, fmt.Errorf("krpc: t: %w", err)
+	}
+	if len(m.TransactionID) == 0 {
+		return m, fmt.Errorf("krpc: empty transaction id")
+	}
+	if m.Type, err = b.GetDictString("y"); err != nil {
+		return m, fmt.Errorf("krpc: y: %w", err)
+	}
+	if m.Version, err = opt.String(d, "v"); err != nil {
+		return m, err
+	}
+
+	switch m.Type {
+	case TypeQuery:
+		if m.Method, err = b.GetDictString("q"); err != nil {
+			return m, fmt.Errorf("krpc: q: %w", err)
+		}
+		a, err := b.GetDictValue("a")
+		if err != nil {
+			return m, fmt.Errorf("krpc: a: %w", err)
+		}
+		if m.Args, err = decodeArgs(m.Method, a); err != nil {
+			return m, err
+		}
+	case TypeResponse:
+		r, err := b.GetDictValue("r")
+		if err != nil {
+			return m, fmt.Errorf("krpc: r: %w", err)
+		}
+		if m.Return, err = decodeReturn(r); err != nil {
+			return m, err
+		}
+	case TypeError:
+		l, err := b.GetDictList("e")
+		if err != nil {
+			return m, fmt.Errorf("krpc: e: %w", err)
+		}
+		if len(l) != 2 {
+			return m, fmt.Errorf("krpc: e has %d elements, expected 2", len(l))
+		}
+		if m.Error.Code, err = l[0].GetInt(); err != nil {
+			return m, fmt.Errorf("krpc: error code: %w", err)
+		}
+		if m.Error.Message, err = l[1].GetString(); err != nil {
+			return m, fmt.Errorf("krpc: error message: %w", err)
+		}
+	default:
+		return m, fmt.Errorf("krpc: unknown message type %q", m.Type)
+	}
+
+	return m, nil
+}
+
+func decodeArgs(method string, a bencode.Belement) (Args, error) {
+	args := Args{}
+
+	d, err := a.GetDict()
+	if err != nil {
+		return args, fmt.Errorf("krpc: a: %w", err)
+	}
+	if args.ID, err = nodeID(d, "id"); err != nil {
+		return args, err
+	}
+
+	switch method {
+	case MethodPing:
+	case MethodFindNode:
+		if args.Target, err = nodeID(d, "target"); err != nil {
+			return args, err
+		}
+	case MethodGetPeers:
+		if args.InfoHash, err = nodeID(d, "info_hash"); err != nil {
+			return args, err
+		}
+	case MethodAnnouncePeer:
+		if args.InfoHash, err = nodeID(d, "info_hash"); err != nil {
+			return args, err
+		}
+		if args.Token, err = a.GetDictString("token"); err != nil {
+			return args, fmt.Errorf("krpc: token: %w", err)
+		}
+		implied, err := opt.Int(d, "implied_port")
+		if err != nil {
+			return args, err
+		}
+		args.ImpliedPort = implied != 0
+		if args.Port, err = a.GetDictInt("port"); err != nil && !args.ImpliedPort {
+			return args, fmt.Errorf("krpc: port: %w", err)
+		}
+		if args.Port < 0 || args.Port > 65535 {
+			return args, fmt.Errorf("krpc: port %d out of range", args.Port)
+		}
+	case MethodGet:
+		if args.Target, err = nodeID(d, "target"); err != nil {
+			return args, err
+		}
+		if args.Seq, err = opt.IntPtr(d, "seq"); err != nil {
+			return args, err
+		}
+	case MethodPut:
+		if args.Token, err = a.GetDictString("token"); err != nil {
+			return args, fmt.Errorf("krpc: token: %w", err)
+		}
+		if args.Value, err = a.GetDictValue("v"); err != nil {
+			return args, fmt.Errorf("krpc: v: %w", err)
+		}
+		if err := checkValue(args.Value); err != nil {
+			return args, err
+		}
+		if args.Key, err = optFixedString(d, "k", KeyLen); err != nil {
+			return args, err
+		}
+		if args.Signature, err = optFixedString(d, "sig", SigLen); err != nil {
+			return args, err
+		}
+		if args.Salt, err = opt.String(d, "salt"); err != nil {
+			return args, err
+		}
+		if len(args.Salt) > MaxSaltLen {
+			return args, fmt.Errorf("krpc: salt has length %d, maximum is %d", len(args.Salt), MaxSaltLen)
+		}
+		if args.Seq, err = opt.IntPtr(d, "seq"); err != nil {
+			return args, err
+		}
+		if args.CAS, err = opt.IntPtr(d, "cas"); err != nil {
+			return args, err
+		}
+		// mutable items must carry the key, signature and sequence number together
+		if args.Key != "" || args.Signature != "" || args.Seq != nil {
+			if args.Key == "" || args.Signature == "" || args.Seq == nil {
+				return args, fmt.Errorf("krpc: mutable put requires k, sig and seq")
+			}
+		} else if args.Salt != "" || args.CAS != nil {
+			return args, fmt.Errorf("krpc: salt and cas are only valid for mutable puts")
+		}
+	default:
+		// the error to reply with, as the query cannot be answered
+		return args, Error{Code: ErrorMethodUnknown, Message: "Method Unknown"}
+	}
+
+	return args, nil
+}
+
+func decodeReturn(r bencode.Belement) (Return, error) {
+	ret := Return{}
+
+	d, err := r.GetDict()
+	if err != nil {
+		return ret, fmt.Errorf("krpc: r: %w", err)
+	}
+	if ret.ID, err = nodeID(d, "id"); err != nil {
+		return ret, err
+	}
+
+	nodes, err := opt.String(d, "nodes")
+	if err != nil {
+		return ret, err
+	}
+	if ret.Nodes, err = UnpackNodes(nodes); err != nil {
+		return ret, err
+	}
+	nodes6, err := opt.String(d, "nodes6")
+	if err != nil {
+		return ret, err
+	}
+	if ret.Nodes6, err = UnpackNodes6(nodes6); err != nil {
+		return ret, err
+	}
+
+	if ret.Token, err = opt.String(d, "token"); err != nil {
+		return ret, err
+	}
+
+	if v, ok := d["values"]; ok {
+		l, err := v.GetList()
+		if err != nil {
+			return ret, fmt.Errorf("krpc: values: %w", err)
+		}
+		ret.Values = make([]netip.AddrPort, 0, len(l))
+		for i := range l {
+			s, err := l[i].GetString()
+			if err != nil {
+				return ret, fmt.Errorf("krpc: values: %w", err)
+			}
+			p, err := compact.UnpackPeer(s)
+			if err != nil {
+				return ret, fmt.Errorf("krpc: values: %w", err)
+			}
+			ret.Values = append(ret.Values, p)
+		}
+	}
+
+	if v, ok := d["v"]; ok {
+		if err := checkValue(v); err != nil {
+			return ret, err
+		}
+		ret.Value = v
+	}
+	if ret.Key, err = optFixedString(d, "k", KeyLen); err != nil {
+		return ret, err
+	}
+	if ret.Signature, err = optFixedString(d, "sig", SigLen); err != nil {
+		return ret, err
+	}
+	if ret.Seq, err = opt.IntPtr(d, "seq"); err != nil {
+		return ret, err
+	}
+
+	return ret, nil
+}
+
+func (m Message) Encode() ([]byte, error) {
+	d := map[string]interface{}{
+		"t": m.TransactionID,
+		"y": m.Type,
+	}
+	if m.Version != "" {
+		d["v"] = m.Version
+	}
+
+	switch m.Type {
+	case TypeQuery:
+		d["q"] = m.Method
+		a, err := m.Args.encode(m.Method)
+		if err != nil {
+			return nil, err
+		}
+		d["a"] = a
+	case TypeResponse:
+		r, err := m.Return.encode()
+		if err != nil {
+			return nil, err
+		}
+		d["r"] = r
+	case TypeError:
+		d["e"] = []interface{}{m.Error.Code, m.Error.Message}
+	default:
+		return nil, fmt.Errorf("krpc: unknown message type %q", m.Type)
+	}
+
+	return bencode.EncodeDict(d)
+}
+
+func (a Args) encode(method string) (map[string]interface{}, error) {
+	d := map[string]interface{}{"id": string(a.ID[:])}
+
+	switch method {
+	case MethodFindNode, MethodGet:
+		d["target"] = string(a.Target[:])
+	case MethodGetPeers:
+		d["info_hash"] = string(a.InfoHash[:])
+	case MethodAnnouncePeer:
+		d["info_hash"] = string(a.InfoHash[:])
+		d["port"] = a.Port
+		d["token"] = a.Token
+		if a.ImpliedPort {
+			d["implied_port"] = 1
+		}
+	case MethodPut:
+		if a.Value.Type == bencode.TypeInvalid {
+			return nil, fmt.Errorf("krpc: put requires a value")
+		}
+		d["v"] = a.Value
+		d["token"] = a.Token
+		if a.Key != "" {
+			d["k"] = a.Key
+			d["sig"] = a.Signature
+		}
+		if a.Salt != "" {
+			d["salt"] = a.Salt
+		}
+		if a.CAS != nil {
+			d["cas"] = *a.CAS
+		}
+	}
+
+	if a.Seq != nil && (method == MethodGet || method == MethodPut) {
+		d["seq"] = *a.Seq
+	}
+	return d, nil
+}
+
+func (r Return) encode() (map[string]interface{}, error) {
+	d := map[string]interface{}{"id": string(r.ID[:])}
+
+	if len(r.Nodes) > 0 {
+		s, err := PackNodes(r.Nodes)
+		if err != nil {
+			return nil, err
+		}
+		d["nodes"] = s
+	}
+	if len(r.Nodes6) > 0 {
+		s, err := PackNodes6(r.Nodes6)
+		if err != nil {
+			return nil, err
+		}
+		d["nodes6"] = s
+	}
+	if r.Token != "" {
+		d["token"] = r.Token
+	}
+	if r.Values != nil {
+		values := make([]interface{}, 0, len(r.Values))
+		for _, p := range r.Values {
+			var b []byte
+			var err error
+			if p.Addr().Unmap().Is4() {
+				b, err = compact.AppendPeer(nil, p)
+			} else {
+				b, err = compact.AppendPeer6(nil, p)
+			}
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, string(b))
+		}
+		d["values"] = values
+	}
+
+	if r.Value.Type != bencode.TypeInvalid {
+		d["v"] = r.Value
+	}
+	if r.Key != "" {
+		d["k"] = r.Key
+		d["sig"] = r.Signature
+	}
+	if r.Seq != nil {
+		d["seq"] = *r.Seq
+	}
+	return d, nil
+}
+
+func PackNodes(nodes []NodeInfo) (string, error) {
+	ret := make([]byte, 0, len(nodes)*NodeInfoLen)
+	for _, n := range nodes {
+		var err error
+		ret = append(ret, n.ID[:]...)
+		if ret, err = compact.AppendPeer(ret, n.Addr); err != nil {
+			return "", err
+		}
+	}
+	return string(ret), nil
+}
+
+func PackNodes6(nodes []NodeInfo) (string, error) {
+	ret := make([]byte, 0, len(nodes)*NodeInfo6Len)
+	for _, n := range nodes {
+		var err error
+		ret = append(ret, n.ID[:]...)
+		if ret, err = compact.AppendPeer6(ret, n.Addr); err != nil {
+			return "", err
+		}
+	}
+	return string(ret), nil
+}
+
+func UnpackNodes(data string) ([]NodeInfo, error) {
+	return unpackNodes(data, NodeInfoLen)
+}
+
+func UnpackNodes6(data string) ([]NodeInfo, error) {
+	return unpackNodes(data, NodeInfo6Len)
+}
+
+func unpackNodes(data string, size int) ([]NodeInfo, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	if len(data)%size != 0 {
+		return nil, fmt.Errorf("krpc: node list length %d is not a multiple of %d", len(data), size)
+	}
+	nodes := make([]NodeInfo, 0, len(data)/size)
+	for i := 0; i < len(data); i += size {
+		addr, err := compact.UnpackPeer(data[i+20 : i+size])
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, NodeInfo{ID: NodeID([]byte(data[i : i+20])), Addr: addr})
+	}
+	return nodes, nil
+}
+
+func checkValue(v bencode.Belement) error {
+	e, err := v.Encode()
+	if err != nil {
+		return fmt.Errorf("krpc: v: %w", err)
+	}
+	if len(e) > MaxValueLen {
+		return fmt.Errorf("krpc: v has encoded length %d, maximum is %d", len(e), MaxValueLen)
+	}
+	return nil
+}
+
+func nodeID(d map[string]bencode.Belement, key string) (NodeID, error) {
+	id := NodeID{}
+	s, err := optFixedString(d, key, len(id))
+	if err != nil {
+		return id, err
+	}
+	if s == "" {
+		return id, fmt.Errorf("krpc: missing %s", key)
+	}
+	copy(id[:], s)
+	return id, nil
+}
+
+func optFixedString(d map[string]bencode.Belement, key string, size int) (string, error) {
+	s, err := opt.String(d, key)
+	if _, ok := d[key]; err != nil || !ok {
+		return s, err
+	}
+	if len(s) != size {
+		return "", fmt.Errorf("krpc: %s has length %d, expected %d", key, len(s), size)
+	}
+	return s, nil
+}
+
+// opt reads the optional keys of a dict.
+var opt = dictutil.Reader("krpc")
@@ -0,0 +1,179 @@
+package krpc_test
+
+import (
+	"errors"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/deathcrafter/bencode"
+	"github.com/deathcrafter/bencode/krpc"
+)
+
+var id = krpc.NodeID([]byte("abcdefghij0123456789"))
+
+func TestPing(t *testing.T) {
+	data := "d1:ad2:id20:abcdefghij0123456789e1:q4:ping1:t2:aa1:y1:qe"
+	m, err := krpc.Decode([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Type != krpc.TypeQuery || m.Method != krpc.MethodPing || m.TransactionID != "aa" || m.Args.ID != id {
+		t.Fatalf("Unexpected message %+v", m)
+	}
+
+	e, err := m.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(e) != data {
+		t.Fatalf("Expected %s, got %s", data, string(e))
+	}
+}
+
+func TestFindNodeResponse(t *testing.T) {
+	m := krpc.Message{
+		TransactionID: "aa",
+		Type:          krpc.TypeResponse,
+		Return: krpc.Return{
+			ID: id,
+			Nodes: []krpc.NodeInfo{
+				{ID: id, Addr: netip.MustParseAddrPort("10.0.0.1:6881")},
+			},
+			Nodes6: []krpc.NodeInfo{
+				{ID: id, Addr: netip.MustParseAddrPort("[2001:db8::1]:6881")},
+			},
+		},
+	}
+	e, err := m.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := krpc.Decode(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Return.Nodes) != 1 || d.Return.Nodes[0] != m.Return.Nodes[0] {
+		t.Fatalf("Expected %v, got %v", m.Return.Nodes, d.Return.Nodes)
+	}
+	if len(d.Return.Nodes6) != 1 || d.Return.Nodes6[0] != m.Return.Nodes6[0] {
+		t.Fatalf("Expected %v, got %v", m.Return.Nodes6, d.Return.Nodes6)
+	}
+}
+
+func TestGetPeersValues(t *testing.T) {
+	data := "d1:rd2:id20:abcdefghij01234567895:token2:tk6:valuesl6:\x0a\x00\x00\x01\x1a\xe1ee1:t2:aa1:y1:re"
+	m, err := krpc.Decode([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Return.Token != "tk" || len(m.Return.Values) != 1 || m.Return.Values[0] != netip.MustParseAddrPort("10.0.0.1:6881") {
+		t.Fatalf("Unexpected return %+v", m.Return)
+	}
+
+	e, err := m.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(e) != data {
+		t.Fatalf("Expected %q, got %q", data, string(e))
+	}
+}
+
+func TestAnnouncePeerImpliedPort(t *testing.T) {
+	data := "d1:ad2:id20:abcdefghij012345678912:implied_porti1e9:info_hash20:mnopqrstuvwxyz1234564:porti0e5:token2:tke1:q13:announce_peer1:t2:aa1:y1:qe"
+	m, err := krpc.Decode([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.Args.ImpliedPort || m.Args.Token != "tk" {
+		t.Fatalf("Unexpected args %+v", m.Args)
+	}
+}
+
+func TestError(t *testing.T) {
+	data := "d1:eli201e13:Generic Errore1:t2:aa1:y1:ee"
+	m, err := krpc.Decode([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Error.Code != krpc.ErrorGeneric || m.Error.Message != "Generic Error" {
+		t.Fatalf("Unexpected error %+v", m.Error)
+	}
+
+	e, err := m.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(e) != data {
+		t.Fatalf("Expected %s, got %s", data, string(e))
+	}
+	t.Log(m.Error)
+}
+
+func TestUnknownMethod(t *testing.T) {
+	m, err := krpc.Decode([]byte("d1:ad2:id20:abcdefghij0123456789e1:q4:frob1:t2:aa1:y1:qe"))
+	var kerr krpc.Error
+	if !errors.As(err, &kerr) || kerr.Code != krpc.ErrorMethodUnknown {
+		t.Fatalf("Expected method unknown error, got %v", err)
+	}
+	// the transaction id is kept for the reply
+	if m.TransactionID != "aa" || m.Method != "frob" {
+		t.Fatalf("Unexpected message %+v", m)
+	}
+}
+
+func TestMutablePut(t *testing.T) {
+	seq := 4
+	m := krpc.Message{
+		TransactionID: "aa",
+		Type:          krpc.TypeQuery,
+		Method:        krpc.MethodPut,
+		Args: krpc.Args{
+			ID:        id,
+			Token:     "tk",
+			Value:     bencode.Belement{Type: bencode.TypeString, Value: "Hello World!"},
+			Key:       strings.Repeat("k", krpc.KeyLen),
+			Signature: strings.Repeat("s", krpc.SigLen),
+			Salt:      "foobar",
+			Seq:       &seq,
+		},
+	}
+	e, err := m.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := krpc.Decode(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := d.Args.Value.GetString(); v != "Hello World!" || d.Args.Seq == nil || *d.Args.Seq != 4 || d.Args.Salt != "foobar" {
+		t.Fatalf("Unexpected args %+v", d.Args)
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	for _, data := range []string{
+		"le",
+		"d1:t0:1:y1:qe",
+		"d1:t2:aa1:y1:xe",
+		"d1:ad2:id3:abce1:q4:ping1:t2:aa1:y1:qe",
+		"d1:ad2:id20:abcdefghij0123456789e1:q9:find_node1:t2:aa1:y1:qe",
+		"d1:rd2:id20:abcdefghij01234567895:nodes3:abce1:t2:aa1:y1:re",
+		"d1:rd2:id20:abcdefghij01234567896:valuesl3:abcee1:t2:aa1:y1:re",
+		"d1:eli201ee1:t2:aa1:y1:ee",
+		"d1:ad2:id20:abcdefghij01234567891:k3:abc5:token2:tk1:vi1ee1:q3:put1:t2:aa1:y1:qe",
+		"d1:ad2:id20:abcdefghij01234567894:salt3:abc5:token2:tk1:vi1ee1:q3:put1:t2:aa1:y1:qe",
+		"d1:ad2:id20:abcdefghij01234567895:token2:tk1:v1001:" + strings.Repeat("x", 1001) + "e1:q3:put1:t2:aa1:y1:qe",
+	} {
+		if _, err := krpc.Decode([]byte(data)); err == nil {
+			t.Fatalf("Expected error for %q, got nil", data)
+		} else {
+			t.Log(err)
+		}
+	}
+}
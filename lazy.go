@@ -0,0 +1,188 @@
+package bencode
+
+import (
+	"bytes"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// DecodeLazy decodes the first element of data like Decode, accepting the
+// same documents, and leaves its lists and dicts unparsed. The element is
+// validated and the bounds of every list and dict are indexed in a single
+// pass; the children of one are parsed when it is first read through
+// GetList, GetDict or the getters built on them, so reading the name of a
+// torrent does not build its file list.
+//
+// The elements returned refer to data, which must not be modified while they
+// are in use. Encode copies lists and dicts that were never read straight
+// from data when they are canonical. Their Value is not a []Belement or
+// map[string]Belement until read, so compare them with Equal rather than
+// reflect.DeepEqual.
+func DecodeLazy(data []byte) (Belement, error) {
+	return decodeIndexed(&lazyIndex{data: data}, true)
+}
+
+// decodeIndexed validates x.data and returns its element, with lists and
+// dicts left to be parsed on access when lazy is set.
+func decodeIndexed(x *lazyIndex, lazy bool) (Belement, error) {
+	s := scanner{data: x.data}
+	if lazy {
+		s.index = x
+	}
+	if _, err := s.value(0); err != nil {
+		return InvalidBelement, err
+	}
+	if lazy {
+		b, _ := x.element(0, 0)
+		return b, nil
+	}
+	b, _ := x.tree(0)
+	return b, nil
+}
+
+// lazyIndex holds the bounds of the lists and dicts of a document in the
+// order they start.
+type lazyIndex struct {
+	data         []byte
+	spans        []lazySpan
+	noncanonical int  // elements out of canonical form seen so far
+	noCopy       bool // strings refer to data instead of copying it
+}
+
+type lazySpan struct {
+	start, end int
+	canonical  bool // everything within is in canonical form
+}
+
+type lazyMark struct {
+	span, noncanonical int
+}
+
+func (x *lazyIndex) open(pos int) lazyMark {
+	if x == nil {
+		return lazyMark{}
+	}
+	x.spans = append(x.spans, lazySpan{start: pos})
+	return lazyMark{span: len(x.spans) - 1, noncanonical: x.noncanonical}
+}
+
+func (x *lazyIndex) close(m lazyMark, end int) {
+	if x == nil {
+		return
+	}
+	x.spans[m.span].end = end
+	x.spans[m.span].canonical = x.noncanonical == m.noncanonical
+}
+
+// element returns the element at pos, which has been validated, and the
+// offset just past it. Lists and dicts are looked up from span on.
+func (x *lazyIndex) element(pos, span int) (Belement, int) {
+	switch x.data[pos] {
+	case 'i':
+		end := pos + bytes.IndexByte(x.data[pos:], 'e')
+		v, _, _ := parseInt(x.data[pos+1 : end])
+		return Belement{Type: TypeInt, Value: v}, end + 1
+	case 'l', 'd':
+		i, _ := slices.BinarySearchFunc(x.spans[span:], pos, func(s lazySpan, pos int) int {
+			return s.start - pos
+		})
+		n := &lazyNode{index: x, span: span + i}
+		if x.data[pos] == 'l' {
+			return Belement{Type: TypeList, Value: n}, x.spans[n.span].end
+		}
+		return Belement{Type: TypeDict, Value: n}, x.spans[n.span].end
+	default:
+		s, end, _ := scanner{data: x.data}.str(pos)
+		return Belement{Type: TypeString, Value: x.string(s)}, end
+	}
+}
+
+// tree returns the element at pos like element, with its lists and dicts
+// parsed.
+func (x *lazyIndex) tree(pos int) (Belement, int) {
+	switch x.data[pos] {
+	case 'l':
+		l := []Belement{}
+		for pos++; x.data[pos] != 'e'; {
+			var e Belement
+			e, pos = x.tree(pos)
+			l = append(l, e)
+		}
+		return Belement{Type: TypeList, Value: l}, pos + 1
+	case 'd':
+		d := map[string]Belement{}
+		for pos++; x.data[pos] != 'e'; {
+			k, next, _ := scanner{data: x.data}.str(pos)
+			d[x.string(k)], pos = x.tree(next)
+		}
+		return Belement{Type: TypeDict, Value: d}, pos + 1
+	default:
+		return x.element(pos, 0)
+	}
+}
+
+func (x *lazyIndex) string(b []byte) string {
+	if x.noCopy {
+		return unsafe.String(unsafe.SliceData(b), len(b))
+	}
+	return string(b)
+}
+
+// lazyNode is the Value of a list or dict from DecodeLazy.
+type lazyNode struct {
+	index  *lazyIndex
+	span   int
+	once   sync.Once
+	loaded atomic.Bool
+	value  interface{} // []Belement or map[string]Belement once loaded
+}
+
+func (n *lazyNode) load() interface{} {
+	n.once.Do(func() {
+		x, s := n.index, n.index.spans[n.span]
+		// the spans of the children follow that of n
+		span := n.span + 1
+		if x.data[s.start] == 'l' {
+			l := []Belement{}
+			for pos := s.start + 1; pos < s.end-1; {
+				var e Belement
+				e, pos = x.element(pos, span)
+				l = append(l, e)
+			}
+			n.value = l
+		} else {
+			d := map[string]Belement{}
+			for pos := s.start + 1; pos < s.end-1; {
+				k, next, _ := scanner{data: x.data}.str(pos)
+				d[x.string(k)], pos = x.element(next, span)
+			}
+			n.value = d
+		}
+		n.loaded.Store(true)
+	})
+	return n.value
+}
+
+// lazyRaw returns the encoding of v when it is a lazy list or dict that has
+// not been read, and so cannot have been changed, and is canonical.
+func lazyRaw(v Belement) ([]byte, bool) {
+	n, ok := v.Value.(*lazyNode)
+	if !ok || n.loaded.Load() {
+		return nil, false
+	}
+	s := n.index.spans[n.span]
+	if !s.canonical {
+		return nil, false
+	}
+	return n.index.data[s.start:s.end], true
+}
+
+// resolve returns v with a lazy list or dict replaced by its children.
+func resolve(v interface{}) interface{} {
+	if n, ok := v.(*lazyNode); ok {
+		return n.load()
+	}
+	return v
+}
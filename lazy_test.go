@@ -0,0 +1,189 @@
+package bencode_test
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/deathcrafter/bencode"
+)
+
+// largeTorrent encodes a torrent with n files.
+func largeTorrent(t testing.TB, n int) []byte {
+	files := make([]interface{}, n)
+	for i := range files {
+		files[i] = map[string]interface{}{
+			"length": 1 << 20,
+			"path":   []interface{}{"dir", fmt.Sprintf("file%d.bin", i)},
+		}
+	}
+	data, err := bencode.Marshal(map[string]interface{}{
+		"announce":      "http://tracker.example.com:6969/announce",
+		"announce-list": []interface{}{[]interface{}{"http://tracker.example.com:6969/announce"}, []interface{}{"udp://backup.example.com:1337"}},
+		"info": map[string]interface{}{
+			"name":         "example",
+			"piece length": 262144,
+			"pieces":       string(make([]byte, 20*n)),
+			"files":        files,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestDecodeLazy(t *testing.T) {
+	data := largeTorrent(t, 100)
+	b, err := bencode.DecodeLazy(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := b.GetDictValue("info")
+	if err != nil {
+		t.Fatal(err)
+	}
+	name, err := info.GetDictString("name")
+	if err != nil || name != "example" {
+		t.Fatalf("Unexpected name %q, %v", name, err)
+	}
+	tiers, err := b.GetDictList("announce-list")
+	if err != nil || len(tiers) != 2 {
+		t.Fatalf("Unexpected announce-list %v, %v", tiers, err)
+	}
+	tier, err := tiers[1].GetStringList()
+	if err != nil || len(tier) != 1 || tier[0] != "udp://backup.example.com:1337" {
+		t.Fatalf("Unexpected tier %v, %v", tier, err)
+	}
+	path, err := b.Get(bencode.Path{}.Key("info").Key("files").Index(42).Key("path"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := path.GetListString(1); s != "file42.bin" {
+		t.Fatalf("Unexpected path %v", path)
+	}
+
+	eager, err := bencode.Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bencode.Equal(b, eager) {
+		t.Fatal("Lazy and eager elements differ")
+	}
+	e, err := b.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(e) != string(data) {
+		t.Fatalf("Expected %q, got %q", data, e)
+	}
+}
+
+func TestDecodeLazyEncode(t *testing.T) {
+	// unsorted keys are sorted and padded numbers trimmed when encoding,
+	// even when the list or dict is not read
+	b, err := bencode.DecodeLazy([]byte("l1:ad1:bi2e1:ai1eeli1eeli01e02:abee"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := b.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(e) != "l1:ad1:ai1e1:bi2eeli1eeli1e2:abee" {
+		t.Fatalf("Unexpected encoding %q", e)
+	}
+
+	// a list that has been read may have been changed
+	l, err := b.GetListList(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l[0] = bencode.Belement{Type: bencode.TypeInt, Value: 7}
+	e, err = b.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(e) != "l1:ad1:ai1e1:bi2eeli7eeli1e2:abee" {
+		t.Fatalf("Unexpected encoding %q", e)
+	}
+}
+
+func TestDecodeLazyErrors(t *testing.T) {
+	for _, data := range []string{"", "l", "li1e", "d1:ae", "i1.5e", "ld1:ai0x3eee"} {
+		if _, err := bencode.DecodeLazy([]byte(data)); err == nil {
+			t.Errorf("Expected error for %q", data)
+		}
+	}
+
+	// nesting too deep for the stack is an error rather than a crash
+	deep := []byte(strings.Repeat("l", 20<<20))
+	if _, err := bencode.DecodeLazy(deep); err == nil || !strings.Contains(err.Error(), "Nesting deeper than") {
+		t.Fatalf("Expected nesting error, got %v", err)
+	}
+	nested := strings.Repeat("l", bencode.MaxDepth) + strings.Repeat("e", bencode.MaxDepth)
+	if _, err := bencode.DecodeLazy([]byte(nested)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bencode.DecodeLazy([]byte("l" + nested + "e")); err == nil {
+		t.Fatal("Expected error for nesting one deeper than MaxDepth")
+	}
+}
+
+func TestDecodeLazyConcurrent(t *testing.T) {
+	b, err := bencode.DecodeLazy(largeTorrent(t, 10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			info, err := b.GetDictValue("info")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if files, err := info.GetDictList("files"); err != nil || len(files) != 10 {
+				t.Errorf("Unexpected files %v, %v", files, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func BenchmarkDecodeMetadata(b *testing.B) {
+	data := largeTorrent(b, 10000)
+	read := func(b *testing.B, v bencode.Belement) {
+		if _, err := v.Get(bencode.Path{}.Key("info").Key("name")); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := v.GetDictList("announce-list"); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.Run("Decode", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			v, err := bencode.Decode(data)
+			if err != nil {
+				b.Fatal(err)
+			}
+			read(b, v)
+		}
+	})
+	b.Run("DecodeLazy", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			v, err := bencode.DecodeLazy(data)
+			if err != nil {
+				b.Fatal(err)
+			}
+			read(b, v)
+		}
+	})
+}
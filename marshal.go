@@ -0,0 +1,398 @@
+package bencode
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+)
+
+var belementType = reflect.TypeOf(Belement{})
+
+// Marshaler is implemented by types that encode themselves, such as those
+// generated by cmd/bencodegen. MarshalBencode must return exactly one
+// element.
+type Marshaler interface {
+	MarshalBencode() ([]byte, error)
+}
+
+// AppendMarshaler is the append form of Marshaler, preferred when a type
+// implements both.
+type AppendMarshaler interface {
+	AppendBencode(dst []byte) ([]byte, error)
+}
+
+var (
+	marshalerType       = reflect.TypeOf((*Marshaler)(nil)).Elem()
+	appendMarshalerType = reflect.TypeOf((*AppendMarshaler)(nil)).Elem()
+)
+
+// Marshal encodes v. Integers and bools become ints, strings, []byte and
+// byte arrays become strings, slices and arrays become lists, and maps and
+// structs become dicts. Nil pointers, nil interfaces and invalid Belements
+// are left out of lists and dicts.
+//
+// Map keys may be of a string kind, used as is, implement
+// encoding.TextMarshaler, giving their text, or be byte arrays such as info
+// hashes, giving their raw bytes. Exported struct fields use their name as
+// the key unless a tag of the form
+//
+//	`bencode:"key,omitempty,required,inline,default=value"`
+//
+// gives another; every part is optional and a tag of "-" skips the field.
+// Fields tagged omitempty are left out when empty, embedded structs and
+// fields tagged inline have their fields written into the outer dict, and
+// required and default apply to Unmarshal. Keys are written in canonical
+// order whatever the order of the fields.
+//
+// The encoding from a Marshaler or AppendMarshaler is written as is and must
+// be exactly one element.
+func Marshal(v interface{}) ([]byte, error) {
+	e, ok, err := appendReflect(nil, v)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, BencodeError{msg: fmt.Sprintf("Cannot marshal %T", v)}
+	}
+	return e, nil
+}
+
+// appendReflect appends the encoding of v using the plan for its type. ok
+// is false for values that have no encoding, such as nil pointers.
+func appendReflect(dst []byte, v interface{}) ([]byte, bool, error) {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		return dst, false, nil
+	}
+	dst, ok, err := typeEncoder(rv.Type())(dst, rv)
+	return dst, ok, publicError(err)
+}
+
+func newTypeEncoder(t reflect.Type) encoderFunc {
+	if t == belementType {
+		return encodeBelement
+	}
+	if t.Kind() != reflect.Interface {
+		if t.Implements(appendMarshalerType) || t.Implements(marshalerType) {
+			return encodeMarshaler
+		}
+		// methods on the pointer can only be used for addressable values
+		if t.Kind() != reflect.Pointer {
+			pt := reflect.PointerTo(t)
+			if pt.Implements(appendMarshalerType) || pt.Implements(marshalerType) {
+				kind := newKindEncoder(t)
+				return func(dst []byte, rv reflect.Value) ([]byte, bool, error) {
+					if rv.CanAddr() {
+						return encodeMarshaler(dst, rv.Addr())
+					}
+					return kind(dst, rv)
+				}
+			}
+		}
+	}
+	return newKindEncoder(t)
+}
+
+func newKindEncoder(t reflect.Type) encoderFunc {
+	switch t.Kind() {
+	case reflect.Pointer:
+		elem := typeEncoder(t.Elem())
+		return func(dst []byte, rv reflect.Value) ([]byte, bool, error) {
+			if rv.IsNil() {
+				return dst, false, nil
+			}
+			return elem(dst, rv.Elem())
+		}
+	case reflect.Interface:
+		return encodeInterface
+	case reflect.Bool:
+		return encodeBool
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return encodeInt
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return encodeUint
+	case reflect.String:
+		return encodeString
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return encodeBytes
+		}
+		return newListEncoder(t)
+	case reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return encodeByteArray
+		}
+		return newListEncoder(t)
+	case reflect.Map:
+		return newMapEncoder(t)
+	case reflect.Struct:
+		return newStructEncoder(t)
+	default:
+		return func(dst []byte, rv reflect.Value) ([]byte, bool, error) {
+			return nil, false, newPathError("cannot marshal %s", t)
+		}
+	}
+}
+
+func encodeMarshaler(dst []byte, rv reflect.Value) ([]byte, bool, error) {
+	if rv.Kind() == reflect.Pointer && rv.IsNil() {
+		return dst, false, nil
+	}
+	var err error
+	start := len(dst)
+	switch m := rv.Interface().(type) {
+	case AppendMarshaler:
+		dst, err = m.AppendBencode(dst)
+	case Marshaler:
+		var e []byte
+		e, err = m.MarshalBencode()
+		dst = append(dst, e...)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	// the encoding is copied into the document as is, so it must be exactly
+	// one element for the document to decode
+	end, err := scanner{data: dst[start:]}.value(0)
+	if err == nil && start+end != len(dst) {
+		err = SyntaxError{Offset: end, msg: "Trailing data after element"}
+	}
+	if err != nil {
+		return nil, false, newPathError("invalid encoding from %s: %s", rv.Type(), err.Error())
+	}
+	return dst, true, nil
+}
+
+func encodeBelement(dst []byte, rv reflect.Value) ([]byte, bool, error) {
+	var b Belement
+	if rv.CanAddr() {
+		b = *rv.Addr().Interface().(*Belement)
+	} else {
+		b = rv.Interface().(Belement)
+	}
+	if b.Type == TypeInvalid {
+		return dst, false, nil
+	}
+	dst, err := appendBelement(dst, b)
+	return dst, err == nil, err
+}
+
+func encodeInterface(dst []byte, rv reflect.Value) ([]byte, bool, error) {
+	if rv.IsNil() {
+		return dst, false, nil
+	}
+	rv = rv.Elem()
+	return typeEncoder(rv.Type())(dst, rv)
+}
+
+func encodeBool(dst []byte, rv reflect.Value) ([]byte, bool, error) {
+	if rv.Bool() {
+		return AppendInt(dst, 1), true, nil
+	}
+	return AppendInt(dst, 0), true, nil
+}
+
+func encodeInt(dst []byte, rv reflect.Value) ([]byte, bool, error) {
+	return AppendInt(dst, rv.Int()), true, nil
+}
+
+func encodeUint(dst []byte, rv reflect.Value) ([]byte, bool, error) {
+	u := rv.Uint()
+	if u > uint64(maxInt) {
+		return nil, false, newPathError("value %d overflows int", u)
+	}
+	return AppendInt(dst, int64(u)), true, nil
+}
+
+func encodeString(dst []byte, rv reflect.Value) ([]byte, bool, error) {
+	return AppendString(dst, rv.String()), true, nil
+}
+
+func encodeBytes(dst []byte, rv reflect.Value) ([]byte, bool, error) {
+	return AppendBytes(dst, rv.Bytes()), true, nil
+}
+
+func encodeByteArray(dst []byte, rv reflect.Value) ([]byte, bool, error) {
+	if rv.CanAddr() {
+		return AppendBytes(dst, rv.Bytes()), true, nil
+	}
+	n := rv.Len()
+	dst = strconv.AppendInt(dst, int64(n), 10)
+	dst = append(dst, ':')
+	start := len(dst)
+	dst = slices.Grow(dst, n)[:start+n]
+	reflect.Copy(reflect.ValueOf(dst[start:]), rv)
+	return dst, true, nil
+}
+
+func newListEncoder(t reflect.Type) encoderFunc {
+	elem := typeEncoder(t.Elem())
+	return func(dst []byte, rv reflect.Value) ([]byte, bool, error) {
+		dst = append(dst, 'l')
+		for i := 0; i < rv.Len(); i++ {
+			var err error
+			if dst, _, err = elem(dst, rv.Index(i)); err != nil {
+				return nil, false, atIndex(err, i)
+			}
+		}
+		return append(dst, 'e'), true, nil
+	}
+}
+
+type mapEntry struct {
+	key   string
+	value reflect.Value
+}
+
+func newMapEncoder(t reflect.Type) encoderFunc {
+	elem := typeEncoder(t.Elem())
+	return func(dst []byte, rv reflect.Value) ([]byte, bool, error) {
+		entries := make([]mapEntry, 0, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			k, err := mapKey(iter.Key())
+			if err != nil {
+				return nil, false, err
+			}
+			entries = append(entries, mapEntry{k, iter.Value()})
+		}
+		slices.SortFunc(entries, func(a, b mapEntry) int {
+			return CompareKeys(a.key, b.key)
+		})
+
+		dst = append(dst, 'd')
+		for i, e := range entries {
+			if i > 0 && entries[i-1].key == e.key {
+				return nil, false, atKey(newPathError("duplicate key after conversion"), e.key)
+			}
+			var (
+				ok   bool
+				err  error
+				mark = len(dst)
+			)
+			dst = AppendString(dst, e.key)
+			if dst, ok, err = elem(dst, e.value); err != nil {
+				return nil, false, atKey(err, e.key)
+			}
+			if !ok {
+				dst = dst[:mark]
+			}
+		}
+		return append(dst, 'e'), true, nil
+	}
+}
+
+func newStructEncoder(t reflect.Type) encoderFunc {
+	fields, err := typeFields(t)
+	if err != nil {
+		return func(dst []byte, rv reflect.Value) ([]byte, bool, error) {
+			return nil, false, err
+		}
+	}
+	encoders := make([]encoderFunc, len(fields.list))
+	for i, f := range fields.list {
+		encoders[i] = typeEncoder(t.FieldByIndex(f.index).Type)
+	}
+
+	return func(dst []byte, rv reflect.Value) ([]byte, bool, error) {
+		var (
+			extra map[string]Belement
+			keys  []string
+			err   error
+		)
+		if fields.extra != nil {
+			if fv, ok := fieldByIndex(rv, fields.extra.index); ok {
+				extra = fv.Interface().(map[string]Belement)
+			}
+		}
+		if len(extra) != 0 {
+			keys = sortedKeys(extra)
+			for _, k := range keys {
+				if _, ok := fields.byKey(k); ok {
+					return nil, false, atKey(newPathError("extra key collides with field"), k)
+				}
+			}
+		}
+
+		dst = append(dst, 'd')
+		for i, f := range fields.list {
+			// extra keys are merged in so the dict stays in key order
+			for len(keys) != 0 && CompareKeys(keys[0], f.key) < 0 {
+				if dst, err = appendExtra(dst, keys[0], extra[keys[0]]); err != nil {
+					return nil, false, err
+				}
+				keys = keys[1:]
+			}
+
+			fv, set := fieldByIndex(rv, f.index)
+			if !set || f.omitEmpty && isEmptyValue(fv) {
+				continue
+			}
+			var (
+				ok   bool
+				mark = len(dst)
+			)
+			dst = append(dst, f.prefix...)
+			if dst, ok, err = encoders[i](dst, fv); err != nil {
+				return nil, false, atKey(err, f.key)
+			}
+			if !ok {
+				dst = dst[:mark]
+			}
+		}
+		for _, k := range keys {
+			if dst, err = appendExtra(dst, k, extra[k]); err != nil {
+				return nil, false, err
+			}
+		}
+		return append(dst, 'e'), true, nil
+	}
+}
+
+func appendExtra(dst []byte, k string, e Belement) ([]byte, error) {
+	if e.Type == TypeInvalid {
+		return dst, nil
+	}
+	dst, err := appendBelement(AppendString(dst, k), e)
+	if err != nil {
+		return nil, atKey(err, k)
+	}
+	return dst, nil
+}
+
+// mapKey converts a map key to a dict key. String kinds are used as is,
+// encoding.TextMarshaler keys as their text and byte arrays such as info
+// hashes as their raw bytes.
+func mapKey(k reflect.Value) (string, error) {
+	if k.Kind() == reflect.String {
+		return k.String(), nil
+	}
+	if m, ok := k.Interface().(encoding.TextMarshaler); ok {
+		text, err := m.MarshalText()
+		if err != nil {
+			return "", newPathError("cannot marshal key %v: %s", k, err.Error())
+		}
+		return string(text), nil
+	}
+	if k.Kind() == reflect.Array && k.Type().Elem().Kind() == reflect.Uint8 {
+		b := make([]byte, k.Len())
+		reflect.Copy(reflect.ValueOf(b), k)
+		return string(b), nil
+	}
+	return "", newPathError("cannot marshal map with %s keys", k.Type())
+}
+
+func isEmptyValue(rv reflect.Value) bool {
+	switch rv.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return rv.Len() == 0
+	case reflect.Pointer, reflect.Interface:
+		return rv.IsNil()
+	default:
+		return rv.IsZero()
+	}
+}
+
+const maxInt = int(^uint(0) >> 1)
@@ -0,0 +1,479 @@
+package bencode_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/deathcrafter/bencode"
+)
+
+type fileEntry struct {
+	Length int      `bencode:"length"`
+	Path   []string `bencode:"path"`
+}
+
+type common struct {
+	Name        string `bencode:"name,required"`
+	PieceLength int    `bencode:"piece length,default=262144"`
+}
+
+type info struct {
+	common
+	Pieces  []byte      `bencode:"pieces"`
+	Private bool        `bencode:"private,omitempty"`
+	Files   []fileEntry `bencode:"files,omitempty"`
+	Length  int64       `bencode:"length,omitempty"`
+	Cache   string      `bencode:"-"`
+}
+
+type torrent struct {
+	Announce     string           `bencode:"announce"`
+	AnnounceList [][]string       `bencode:"announce-list,omitempty"`
+	Comment      *string          `bencode:"comment"`
+	Info         info             `bencode:"info"`
+	Extra        bencode.Belement `bencode:"extra"`
+}
+
+func TestMarshal(t *testing.T) {
+	v := torrent{
+		Announce: "http://tracker/",
+		Info: info{
+			common:  common{Name: "a.txt", PieceLength: 16384},
+			Pieces:  []byte("\x00\x01"),
+			Private: true,
+			Length:  12,
+			Cache:   "ignored",
+		},
+	}
+	e, err := bencode.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "d8:announce15:http://tracker/4:infod6:lengthi12e4:name5:a.txt12:piece lengthi16384e6:pieces2:\x00\x017:privatei1eee"
+	if string(e) != expected {
+		t.Fatalf("Expected %q, got %q", expected, string(e))
+	}
+}
+
+func TestUnmarshal(t *testing.T) {
+	data := "d8:announce15:http://tracker/7:comment2:hi5:extrali1ee4:infod5:filesld6:lengthi3e4:pathl1:a1:beee4:name1:x6:pieces0:ee"
+	v := torrent{}
+	if err := bencode.Unmarshal([]byte(data), &v); err != nil {
+		t.Fatal(err)
+	}
+
+	if v.Announce != "http://tracker/" || v.Comment == nil || *v.Comment != "hi" {
+		t.Fatalf("Unexpected torrent %+v", v)
+	}
+	if v.Info.Name != "x" || v.Info.PieceLength != 262144 || v.Info.Private {
+		t.Fatalf("Unexpected info %+v", v.Info)
+	}
+	if len(v.Info.Files) != 1 || v.Info.Files[0].Length != 3 || v.Info.Files[0].Path[1] != "b" {
+		t.Fatalf("Unexpected files %+v", v.Info.Files)
+	}
+	if l, _ := v.Extra.GetIntList(); len(l) != 1 || l[0] != 1 {
+		t.Fatalf("Unexpected extra %v", v.Extra)
+	}
+}
+
+func TestUnmarshalRequired(t *testing.T) {
+	v := torrent{}
+	err := bencode.Unmarshal([]byte("d8:announce1:a4:infod6:pieces0:ee"), &v)
+	if err == nil {
+		t.Fatal("Expected error, got nil")
+	}
+	if err.Error() != ".info.name: missing required key" {
+		t.Fatalf("Unexpected error %s", err)
+	}
+}
+
+func TestUnmarshalNative(t *testing.T) {
+	var x interface{}
+	if err := bencode.Unmarshal([]byte("d1:ali1e1:be1:bi2ee"), &x); err != nil {
+		t.Fatal(err)
+	}
+	m := x.(map[string]interface{})
+	if m["b"].(int) != 2 || m["a"].([]interface{})[1].(string) != "b" {
+		t.Fatalf("Unexpected value %v", x)
+	}
+
+	var hash [4]byte
+	if err := bencode.Unmarshal([]byte("4:abcd"), &hash); err != nil {
+		t.Fatal(err)
+	}
+	if string(hash[:]) != "abcd" {
+		t.Fatalf("Expected %s, got %s", "abcd", hash[:])
+	}
+}
+
+func TestUnmarshalInvalid(t *testing.T) {
+	for _, c := range []struct {
+		data string
+		v    interface{}
+	}{
+		{"i300e", new(uint8)},
+		{"i-1e", new(uint)},
+		{"3:abc", new(int)},
+		{"li1ee", new([2]int)},
+		{"3:abc", new([4]byte)},
+		{"d1:ai1ee", new(map[int]int)},
+		{"d4:namei1ee", new(common)},
+		{"d6:pieces1:xe", new(info)},
+	} {
+		if err := bencode.Unmarshal([]byte(c.data), c.v); err == nil {
+			t.Fatalf("Expected error for %s into %T, got nil", c.data, c.v)
+		} else {
+			t.Log(err)
+		}
+	}
+
+	if err := bencode.Unmarshal([]byte("i1e"), 1); err == nil {
+		t.Fatal("Expected error for non-pointer, got nil")
+	}
+}
+
+func TestFieldOptionErrors(t *testing.T) {
+	type badDefault struct {
+		A []int `bencode:"a,default=1"`
+	}
+	type badOption struct {
+		A int `bencode:"a,sometimes"`
+	}
+	type duplicate struct {
+		A int `bencode:"x"`
+		B int `bencode:"x"`
+	}
+	type badInline struct {
+		A int `bencode:",inline"`
+	}
+	for _, v := range []interface{}{badDefault{}, badOption{}, duplicate{}, badInline{}} {
+		if _, err := bencode.Marshal(v); err == nil {
+			t.Fatalf("Expected error for %T, got nil", v)
+		} else {
+			t.Log(err)
+		}
+	}
+}
+
+type Tracker struct {
+	Announce string `bencode:"announce"`
+	Tier     int    `bencode:"tier,default=1"`
+}
+
+type Source struct {
+	Name  string                      `bencode:"source"`
+	Extra map[string]bencode.Belement `bencode:",extra"`
+}
+
+type withPointers struct {
+	*Tracker
+	Info   string  `bencode:"info"`
+	Source *Source `bencode:",inline"`
+}
+
+func TestInlinePointer(t *testing.T) {
+	// nil pointers are left out
+	e, err := bencode.Marshal(withPointers{Info: "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(e) != "d4:info1:xe" {
+		t.Fatalf("Expected %q, got %q", "d4:info1:xe", string(e))
+	}
+
+	v := withPointers{}
+	if err := bencode.Unmarshal(e, &v); err != nil {
+		t.Fatal(err)
+	}
+	// the default of Tier allocates Tracker, but nothing allocates Source
+	if v.Tracker == nil || v.Tracker.Tier != 1 || v.Source != nil {
+		t.Fatalf("Unexpected value %+v", v)
+	}
+
+	data := "d8:announce1:a4:info1:x6:source1:s4:tieri2e7:unknowni1ee"
+	v = withPointers{}
+	if err := bencode.Unmarshal([]byte(data), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Announce != "a" || v.Tier != 2 || v.Source == nil || v.Source.Name != "s" || len(v.Source.Extra) != 1 {
+		t.Fatalf("Unexpected value %+v", v)
+	}
+
+	if e, err = bencode.Marshal(v); err != nil {
+		t.Fatal(err)
+	}
+	if string(e) != data {
+		t.Fatalf("Expected %q, got %q", data, string(e))
+	}
+}
+
+type Cycle struct {
+	*Cycle
+	A int `bencode:"a"`
+}
+
+func TestInlinePointerCycle(t *testing.T) {
+	if _, err := bencode.Marshal(Cycle{}); err == nil {
+		t.Fatal("Expected error for Cycle, got nil")
+	} else {
+		t.Log(err)
+	}
+}
+
+type withExtra struct {
+	Name  string                      `bencode:"name"`
+	Extra map[string]bencode.Belement `bencode:",extra"`
+}
+
+func TestUnmarshalExtraRoundTrip(t *testing.T) {
+	data := "d10:created by4:test4:name1:x8:url-listl1:aee"
+	v := withExtra{}
+	if err := bencode.Unmarshal([]byte(data), &v); err != nil {
+		t.Fatal(err)
+	}
+
+	if v.Name != "x" || len(v.Extra) != 2 {
+		t.Fatalf("Unexpected value %+v", v)
+	}
+	if s, _ := v.Extra["created by"].GetString(); s != "test" {
+		t.Fatalf("Expected %s, got %s", "test", s)
+	}
+
+	e, err := bencode.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(e) != data {
+		t.Fatalf("Expected %s, got %s", data, string(e))
+	}
+
+	v.Extra["name"] = bencode.Belement{Type: bencode.TypeString, Value: "y"}
+	if _, err := bencode.Marshal(v); err == nil {
+		t.Fatal("Expected error for colliding extra key, got nil")
+	}
+}
+
+func TestUnmarshalDisallowUnknownFields(t *testing.T) {
+	data := []byte("d6:pieces0:7:unknowni1ee")
+	opts := bencode.UnmarshalOptions{DisallowUnknownFields: true}
+
+	v := info{}
+	if err := bencode.Unmarshal(data, &v); err == nil {
+		t.Fatal("Expected error for missing name, got nil")
+	}
+
+	data = []byte("d4:name1:x6:pieces0:7:unknowni1ee")
+	if err := bencode.Unmarshal(data, &v); err != nil {
+		t.Fatal(err)
+	}
+	err := opts.Unmarshal(data, &v)
+	if err == nil || err.Error() != ".unknown: unknown key for bencode_test.info" {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	// an extra field takes the unknown keys even when they are disallowed
+	w := withExtra{}
+	if err := opts.Unmarshal(data, &w); err != nil {
+		t.Fatal(err)
+	}
+	if len(w.Extra) != 2 {
+		t.Fatalf("Unexpected extra %v", w.Extra)
+	}
+}
+
+func TestExtraFieldErrors(t *testing.T) {
+	type wrongType struct {
+		Extra map[string]interface{} `bencode:",extra"`
+	}
+	type twoExtras struct {
+		A map[string]bencode.Belement `bencode:",extra"`
+		B map[string]bencode.Belement `bencode:",extra"`
+	}
+	for _, v := range []interface{}{wrongType{}, twoExtras{}} {
+		if _, err := bencode.Marshal(v); err == nil {
+			t.Fatalf("Expected error for %T, got nil", v)
+		} else {
+			t.Log(err)
+		}
+	}
+}
+
+type node struct {
+	Name     string  `bencode:"name"`
+	Children []*node `bencode:"children,omitempty"`
+}
+
+func TestMarshalRecursiveType(t *testing.T) {
+	v := node{Name: "root", Children: []*node{{Name: "a"}, nil, {Name: "b", Children: []*node{{Name: "c"}}}}}
+
+	// the first use of a type builds its plan, so start several at once
+	var wg sync.WaitGroup
+	results := make([]string, 8)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			e, err := bencode.Marshal(v)
+			if err != nil {
+				t.Error(err)
+			}
+			results[i] = string(e)
+		}()
+	}
+	wg.Wait()
+
+	expected := "d8:childrenld4:name1:aed8:childrenld4:name1:cee4:name1:bee4:name4:roote"
+	for _, e := range results {
+		if e != expected {
+			t.Fatalf("Expected %q, got %q", expected, e)
+		}
+	}
+
+	x := node{}
+	if err := bencode.Unmarshal([]byte(expected), &x); err != nil {
+		t.Fatal(err)
+	}
+	if len(x.Children) != 2 || x.Children[1].Children[0].Name != "c" {
+		t.Fatalf("Unexpected value %+v", x)
+	}
+}
+
+// rawMarshaler encodes itself as its own bytes, well formed or not.
+type rawMarshaler string
+
+func (r rawMarshaler) MarshalBencode() ([]byte, error) {
+	return []byte(r), nil
+}
+
+func TestMarshalerInvalid(t *testing.T) {
+	for _, c := range []struct {
+		raw      string
+		expected string
+	}{
+		{"", `.a: invalid encoding from bencode_test.rawMarshaler: Unexpected end of data at offset 0`},
+		{"i1ei2e", `.a: invalid encoding from bencode_test.rawMarshaler: Trailing data after element at offset 3`},
+		{"l1:a", `.a: invalid encoding from bencode_test.rawMarshaler: Invalid list format: missing end of list at offset 4`},
+		{"e", `.a: invalid encoding from bencode_test.rawMarshaler: Invalid string format at offset 0`},
+	} {
+		_, err := bencode.Marshal(map[string]rawMarshaler{"a": rawMarshaler(c.raw), "b": "i1e"})
+		if err == nil || err.Error() != c.expected {
+			t.Errorf("%q: expected %q, got %v", c.raw, c.expected, err)
+		}
+	}
+
+	// a well-formed element is written as is, canonical or not
+	e, err := bencode.Marshal([]rawMarshaler{"i03e", "d1:bi1e1:ai2ee"})
+	if err != nil || string(e) != "li03ed1:bi1e1:ai2eee" {
+		t.Fatalf("Unexpected encoding %q, %v", e, err)
+	}
+}
+
+func TestMarshalErrorPaths(t *testing.T) {
+	_, err := bencode.Marshal(map[string][]interface{}{"a": {1, uint64(1 << 63)}})
+	if err == nil || err.Error() != `.a[1]: value 9223372036854775808 overflows int` {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	x := map[string][]int8{}
+	err = bencode.Unmarshal([]byte("d1:ali1ei300eee"), &x)
+	if err == nil || err.Error() != `.a[1]: value 300 overflows int8` {
+		t.Fatalf("Unexpected error %v", err)
+	}
+}
+
+func benchmarkTorrent() torrent {
+	comment := "benchmark"
+	return torrent{
+		Announce:     "http://tracker.example.com:6969/announce",
+		AnnounceList: [][]string{{"http://tracker.example.com:6969/announce"}, {"udp://backup.example.com:1337"}},
+		Comment:      &comment,
+		Info: info{
+			common: common{Name: "example", PieceLength: 262144},
+			Pieces: make([]byte, 20*64),
+			Files: []fileEntry{
+				{Length: 1024, Path: []string{"a", "b.txt"}},
+				{Length: 2048, Path: []string{"c.txt"}},
+			},
+		},
+	}
+}
+
+func BenchmarkMarshal(b *testing.B) {
+	v := benchmarkTorrent()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := bencode.Marshal(&v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUnmarshal(b *testing.B) {
+	v := benchmarkTorrent()
+	data, err := bencode.Marshal(&v)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		x := torrent{}
+		if err := bencode.Unmarshal(data, &x); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// peerID encodes itself as a fixed width string.
+type peerID struct {
+	client  string
+	version int
+}
+
+func (p *peerID) AppendBencode(dst []byte) ([]byte, error) {
+	return bencode.AppendString(dst, fmt.Sprintf("-%s%04d-", p.client, p.version)), nil
+}
+
+func (p *peerID) UnmarshalBencode(data []byte) error {
+	s := bencode.NewScanner(data)
+	b, err := s.Bytes()
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Sscanf(string(b), "-%2s%04d-", &p.client, &p.version); err != nil {
+		return err
+	}
+	return s.Finish()
+}
+
+func TestMarshaler(t *testing.T) {
+	type announce struct {
+		ID    peerID  `bencode:"id"`
+		Other *peerID `bencode:"other,omitempty"`
+	}
+
+	v := announce{ID: peerID{"qB", 4500}}
+	expected := "d2:id8:-qB4500-e"
+
+	// only an addressable ID can use the pointer method
+	if e, err := bencode.Marshal(&v); err != nil || string(e) != expected {
+		t.Fatalf("Expected %q, got %q, %v", expected, e, err)
+	}
+	if e, err := bencode.Marshal(v); err != nil || string(e) != "d2:iddee" {
+		t.Fatalf("Expected the fields of a copy, got %q, %v", e, err)
+	}
+
+	x := announce{}
+	if err := bencode.Unmarshal([]byte("d2:id8:-qB4500-5:other8:-TR0300-e"), &x); err != nil {
+		t.Fatal(err)
+	}
+	if x.ID != (peerID{"qB", 4500}) || x.Other == nil || *x.Other != (peerID{"TR", 300}) {
+		t.Fatalf("Unexpected value %+v", x)
+	}
+
+	id := peerID{}
+	if err := bencode.Unmarshal([]byte("8:-UT2210-"), &id); err != nil || id != (peerID{"UT", 2210}) {
+		t.Fatalf("Unexpected value %+v, %v", id, err)
+	}
+}
@@ -0,0 +1,31 @@
+package bencode
+
+import (
+	"slices"
+	"strings"
+	"unsafe"
+)
+
+// CompareKeys is the canonical order of dict keys: BEP 3 sorts keys as raw
+// byte strings, not as text. Go compares strings byte by byte, so binary
+// keys, UTF-8 keys and keys converted from []byte or byte arrays all order
+// the same way. Every path that writes or walks dict keys goes through
+// CompareKeys, as does cmd/bencodegen when it orders the fields it writes.
+func CompareKeys(a, b string) int {
+	return strings.Compare(a, b)
+}
+
+// compareKeyBytes is CompareKeys for keys still in encoded data, without
+// copying them.
+func compareKeyBytes(a, b []byte) int {
+	return CompareKeys(unsafe.String(unsafe.SliceData(a), len(a)), unsafe.String(unsafe.SliceData(b), len(b)))
+}
+
+func sortedKeys[V any](d map[string]V) []string {
+	keys := make([]string, 0, len(d))
+	for k := range d {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, CompareKeys)
+	return keys
+}
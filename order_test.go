@@ -0,0 +1,133 @@
+package bencode_test
+
+import (
+	"bytes"
+	"math/rand"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/deathcrafter/bencode"
+)
+
+// randomCanonical writes a random document in canonical form, with binary
+// and UTF-8 keys mixed so that byte order and text order would disagree.
+func randomCanonical(r *rand.Rand, depth int) []byte {
+	randomString := func(min int) string {
+		alphabet := []string{"a", "b", "z", "A", "\x00", "\x7f", "\xc3\xa9", "\xff", "\xe2\x82\xac", "0"}
+		s := ""
+		for n := min + r.Intn(4); n > 0; n-- {
+			s += alphabet[r.Intn(len(alphabet))]
+		}
+		return s
+	}
+
+	kind := r.Intn(4)
+	if depth <= 0 {
+		kind = r.Intn(2)
+	}
+	switch kind {
+	case 0:
+		return []byte("i" + strconv.FormatInt(r.Int63n(1<<40)-1<<39, 10) + "e")
+	case 1:
+		s := randomString(0)
+		return []byte(strconv.Itoa(len(s)) + ":" + s)
+	case 2:
+		b := []byte("l")
+		for n := r.Intn(4); n > 0; n-- {
+			b = append(b, randomCanonical(r, depth-1)...)
+		}
+		return append(b, 'e')
+	default:
+		keys := make([]string, 0)
+		for n := r.Intn(5); n > 0; n-- {
+			keys = append(keys, randomString(0))
+		}
+		slices.Sort(keys)
+		keys = slices.Compact(keys)
+
+		b := []byte("d")
+		for _, k := range keys {
+			b = append(b, strconv.Itoa(len(k))+":"+k...)
+			b = append(b, randomCanonical(r, depth-1)...)
+		}
+		return append(b, 'e')
+	}
+}
+
+func TestCanonicalRoundTripProperty(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		data := randomCanonical(r, 4)
+		if err := bencode.CheckCanonical(data); err != nil {
+			t.Fatalf("Generated non-canonical %q: %s", data, err)
+		}
+
+		b, err := bencode.Decode(data)
+		if err != nil {
+			t.Fatalf("%q: %s", data, err)
+		}
+		e, err := b.Encode()
+		if err != nil {
+			t.Fatalf("%q: %s", data, err)
+		}
+		if !bytes.Equal(e, data) {
+			t.Fatalf("Expected %q, got %q", data, e)
+		}
+
+		m, err := bencode.Marshal(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(m, data) {
+			t.Fatalf("Marshal: expected %q, got %q", data, m)
+		}
+	}
+}
+
+func TestKeyOrderIsByteOrder(t *testing.T) {
+	// "\xc3\xa9" (é) sorts before "\xe2\x82\xac" (€) and both after "z" and
+	// before "\xff", which is not valid UTF-8
+	expected := "d1:z1:a2:\xc3\xa91:b3:\xe2\x82\xac1:c1:\xff1:de"
+
+	d := map[string]interface{}{"\xff": "d", "\xe2\x82\xac": "c", "z": "a", "\xc3\xa9": "b"}
+	e, err := bencode.EncodeDict(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(e) != expected {
+		t.Fatalf("EncodeDict: expected %q, got %q", expected, string(e))
+	}
+
+	arrays := map[[1]byte]string{{0xff}: "x", {'a'}: "y"}
+	e, err = bencode.Marshal(arrays)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(e) != "d1:a1:y1:\xff1:xe" {
+		t.Fatalf("Marshal map: got %q", string(e))
+	}
+
+	// struct fields follow their keys, not their declaration order
+	s := struct {
+		Z int `bencode:"\xff"`
+		A int `bencode:"b"`
+		M int `bencode:"a"`
+	}{1, 2, 3}
+	e, err = bencode.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(e) != "d1:ai3e1:bi2e1:\xffi1ee" {
+		t.Fatalf("Marshal struct: got %q", string(e))
+	}
+	if err := bencode.CheckCanonical(e); err != nil {
+		t.Fatal(err)
+	}
+
+	keys := []string{"\xff", "\xe2\x82\xac", "z", "\xc3\xa9", "Z", ""}
+	slices.SortFunc(keys, bencode.CompareKeys)
+	if !slices.Equal(keys, []string{"", "Z", "z", "\xc3\xa9", "\xe2\x82\xac", "\xff"}) {
+		t.Fatalf("CompareKeys: got %q", keys)
+	}
+}
@@ -0,0 +1,229 @@
+package bencode
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Parser decodes a stream of elements that arrives in chunks of any size,
+// such as from a non-blocking connection. It keeps the elements in progress
+// on an explicit stack, so each byte is read once however the stream is
+// split. Each element is read as Decode reads one, and what follows it is
+// the next element.
+//
+// Its limits guard against untrusted input; exceeding one is a SyntaxError.
+// The zero Parser is ready to use, with the default limits.
+type Parser struct {
+	// MaxDepth bounds the nesting of lists and dicts, MaxDepth by default.
+	MaxDepth int
+	// MaxStringLen bounds the declared length of a string. By default only
+	// MaxSize applies.
+	MaxStringLen int
+	// MaxSize bounds the encoded size of each top-level element, which is
+	// DefaultMaxSize by default.
+	MaxSize int
+
+	offset int // of the next byte to be fed
+	first  int // offset of the top-level element in progress
+	stack  []parseFrame
+	state  parseState
+	start  int    // offset of the int or string in progress
+	num    []byte // digits of an int or string length read so far
+	str    []byte // contents of a string read so far
+	remain int    // bytes of the string still to come
+	err    error
+}
+
+type parseState int
+
+const (
+	stateValue  parseState = iota // between elements
+	stateInt                      // in the digits of an int
+	stateLength                   // in the length of a string
+	stateString                   // in the contents of a string
+)
+
+// parseFrame is a list or dict in progress.
+type parseFrame struct {
+	list   []Belement
+	dict   map[string]Belement
+	key    string
+	hasKey bool // key has been read and waits for its value
+}
+
+// DefaultMaxSize is the size limit of a Parser with no MaxSize.
+const DefaultMaxSize = 16 << 20
+
+// Feed parses chunk and returns the top-level elements it completes. Parts
+// of elements are kept for the next call. After an error, which is a
+// SyntaxError with the offset in the stream, the elements completed before
+// it are returned and every later call fails with the same error.
+func (p *Parser) Feed(chunk []byte) ([]Belement, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	var values []Belement
+	for i := 0; i < len(chunk); {
+		var err error
+		switch p.state {
+		case stateValue:
+			values, err = p.value(values, chunk[i])
+			i++
+			p.offset++
+		case stateInt, stateLength:
+			term := byte('e')
+			if p.state == stateLength {
+				term = ':'
+			}
+			end := bytes.IndexByte(chunk[i:], term)
+			if end == -1 {
+				end = len(chunk) - i
+			}
+			p.num = append(p.num, chunk[i:i+end]...)
+			i += end
+			p.offset += end
+			if i < len(chunk) {
+				// the terminator
+				i++
+				p.offset++
+				values, err = p.number(values)
+			}
+		case stateString:
+			n := min(p.remain, len(chunk)-i)
+			p.str = append(p.str, chunk[i:i+n]...)
+			p.remain -= n
+			i += n
+			p.offset += n
+			if p.remain == 0 {
+				values = p.complete(values, Belement{Type: TypeString, Value: string(p.str)})
+			}
+		}
+		if err == nil && (p.state != stateValue || len(p.stack) != 0) && p.offset-p.first > p.maxSize() {
+			err = SyntaxError{Offset: p.first, msg: fmt.Sprintf("Element larger than %d bytes", p.maxSize())}
+		}
+		if err != nil {
+			p.err = err
+			return values, err
+		}
+	}
+	return values, nil
+}
+
+func (p *Parser) maxSize() int {
+	if p.MaxSize > 0 {
+		return p.MaxSize
+	}
+	return DefaultMaxSize
+}
+
+// Finish reports an error if the stream ends within an element.
+func (p *Parser) Finish() error {
+	if p.err != nil {
+		return p.err
+	}
+	if p.state != stateValue || len(p.stack) != 0 {
+		return SyntaxError{Offset: p.offset, msg: "Unexpected end of data"}
+	}
+	return nil
+}
+
+// value reads c, the first byte of an element or the end of a list or dict.
+func (p *Parser) value(values []Belement, c byte) ([]Belement, error) {
+	var top *parseFrame
+	if len(p.stack) != 0 {
+		top = &p.stack[len(p.stack)-1]
+	}
+	pos := p.offset
+
+	if c == 'e' {
+		switch {
+		case top == nil:
+			return values, SyntaxError{Offset: pos, msg: "Unexpected end of element"}
+		case top.hasKey:
+			return values, SyntaxError{Offset: pos, msg: "Invalid dict format: missing value"}
+		}
+		b := Belement{Type: TypeList, Value: top.list}
+		if top.dict != nil {
+			b = Belement{Type: TypeDict, Value: top.dict}
+		}
+		p.stack = p.stack[:len(p.stack)-1]
+		return p.complete(values, b), nil
+	}
+	if top == nil {
+		p.first = pos
+	}
+	// a string length may have a sign, as with Decode
+	isLength := c >= '0' && c <= '9' || c == '+' || c == '-'
+	if top != nil && top.dict != nil && !top.hasKey && !isLength {
+		return values, SyntaxError{Offset: pos, msg: fmt.Sprintf("Invalid dict key: unexpected %q", c)}
+	}
+	maxDepth := p.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = MaxDepth
+	}
+	if (c == 'l' || c == 'd') && len(p.stack) == maxDepth {
+		return values, SyntaxError{Offset: pos, msg: fmt.Sprintf("Nesting deeper than %d", maxDepth)}
+	}
+
+	switch {
+	case c == 'i':
+		p.state, p.start, p.num = stateInt, pos, p.num[:0]
+	case c == 'l':
+		p.stack = append(p.stack, parseFrame{list: []Belement{}})
+	case c == 'd':
+		p.stack = append(p.stack, parseFrame{dict: map[string]Belement{}})
+	case isLength:
+		p.state, p.start, p.num = stateLength, pos, append(p.num[:0], c)
+	default:
+		return values, SyntaxError{Offset: pos, msg: fmt.Sprintf("Invalid element: unexpected %q", c)}
+	}
+	return values, nil
+}
+
+// number parses the digits in p.num once their terminator has been read.
+func (p *Parser) number(values []Belement) ([]Belement, error) {
+	if p.state == stateInt {
+		v, _, err := parseInt(p.num)
+		if err != nil {
+			return values, SyntaxError{Offset: p.start + 1, msg: err.Error()}
+		}
+		return p.complete(values, Belement{Type: TypeInt, Value: v}), nil
+	}
+
+	n, _, err := parseLength(p.num)
+	if err != nil {
+		return values, SyntaxError{Offset: p.start, msg: err.Error()}
+	}
+	// fail before buffering a string that cannot fit
+	if p.MaxStringLen > 0 && n > p.MaxStringLen {
+		return values, SyntaxError{Offset: p.start, msg: fmt.Sprintf("String longer than %d bytes", p.MaxStringLen)}
+	}
+	if n > p.maxSize()-(p.offset-p.first) {
+		return values, SyntaxError{Offset: p.first, msg: fmt.Sprintf("Element larger than %d bytes", p.maxSize())}
+	}
+	p.state, p.remain, p.str = stateString, n, p.str[:0]
+	if n == 0 {
+		return p.complete(values, Belement{Type: TypeString, Value: ""}), nil
+	}
+	return values, nil
+}
+
+// complete adds a finished element to the list or dict in progress, or to
+// values at the top level.
+func (p *Parser) complete(values []Belement, b Belement) []Belement {
+	p.state = stateValue
+	if len(p.stack) == 0 {
+		return append(values, b)
+	}
+	top := &p.stack[len(p.stack)-1]
+	switch {
+	case top.dict == nil:
+		top.list = append(top.list, b)
+	case !top.hasKey:
+		top.key, top.hasKey = b.Value.(string), true
+	default:
+		top.dict[top.key] = b
+		top.key, top.hasKey = "", false
+	}
+	return values
+}
@@ -0,0 +1,130 @@
+package bencode_test
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/deathcrafter/bencode"
+)
+
+func TestParser(t *testing.T) {
+	messages := []string{
+		"d1:ad2:id20:abcdefghij0123456789e1:q4:ping1:t2:aa1:y1:qe",
+		"i-42e",
+		"d1:rd2:id20:mnopqrstuvwxyz123456e1:t2:aa1:y1:re",
+		"0:",
+		"l3:abcli1eee",
+	}
+	stream := []byte{}
+	for _, m := range messages {
+		stream = append(stream, m...)
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	for round := 0; round < 100; round++ {
+		p := bencode.Parser{}
+		got := []bencode.Belement{}
+		for rest := stream; len(rest) > 0; {
+			n := min(1+rng.Intn(16), len(rest))
+			values, err := p.Feed(rest[:n])
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, values...)
+			rest = rest[n:]
+		}
+		if err := p.Finish(); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(got) != len(messages) {
+			t.Fatalf("Expected %d values, got %d", len(messages), len(got))
+		}
+		for i, m := range messages {
+			e, err := got[i].Encode()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(e) != m {
+				t.Fatalf("Expected %q, got %q", m, e)
+			}
+		}
+	}
+}
+
+func TestParserErrors(t *testing.T) {
+	for _, c := range []struct {
+		chunks []string
+		offset int
+	}{
+		{[]string{"i1e", "e"}, 3},
+		{[]string{"d1:a", "e"}, 4},
+		{[]string{"di1", "e"}, 1},
+		{[]string{"i0", "x3e"}, 1},
+		{[]string{"l-", "1:abc"}, 1},
+		{[]string{"i12345678901234567890", "123e"}, 1},
+		{[]string{"le x"}, 2},
+	} {
+		p := bencode.Parser{}
+		var err error
+		for _, chunk := range c.chunks {
+			if _, err = p.Feed([]byte(chunk)); err != nil {
+				break
+			}
+		}
+		serr, ok := err.(bencode.SyntaxError)
+		if !ok || serr.Offset != c.offset {
+			t.Errorf("%q: expected syntax error at %d, got %v", c.chunks, c.offset, err)
+			continue
+		}
+		// errors are sticky
+		if _, err2 := p.Feed([]byte("i1e")); err2 != err {
+			t.Errorf("%q: expected %v after error, got %v", c.chunks, err, err2)
+		}
+	}
+
+	// the values before an error are returned with it
+	p := bencode.Parser{}
+	values, err := p.Feed([]byte("i1e3:abcx"))
+	if err == nil || len(values) != 2 {
+		t.Fatalf("Unexpected values %v, error %v", values, err)
+	}
+
+	p = bencode.Parser{}
+	if _, err := p.Feed([]byte("l3:ab")); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Finish(); err == nil {
+		t.Fatal("Expected error for incomplete element")
+	}
+}
+
+func TestParserLimits(t *testing.T) {
+	for _, c := range []struct {
+		p      bencode.Parser
+		data   string
+		offset int
+	}{
+		{bencode.Parser{MaxDepth: 2}, "lldeee", 2},
+		{bencode.Parser{MaxStringLen: 3}, "l3:abc4:abcde", 6},
+		{bencode.Parser{MaxSize: 8}, "i1el3:abc4:abcd", 3},
+		{bencode.Parser{MaxSize: 8}, "i1eli1ei2ei3e", 3},
+		{bencode.Parser{}, strings.Repeat("l", bencode.MaxDepth+1), bencode.MaxDepth},
+		{bencode.Parser{}, "99999999:", 0},
+	} {
+		_, err := c.p.Feed([]byte(c.data))
+		serr, ok := err.(bencode.SyntaxError)
+		if !ok || serr.Offset != c.offset {
+			t.Errorf("%q: expected syntax error at %d, got %v", c.data, c.offset, err)
+		}
+	}
+
+	// the limits apply to each top-level element
+	p := bencode.Parser{MaxSize: 5, MaxDepth: 1}
+	for i := 0; i < 10; i++ {
+		if _, err := p.Feed([]byte("l1:ae")); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package bencode
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PathElement addresses a dict key or, when IsIndex is set, a list index.
+type PathElement struct {
+	Key     string
+	Index   int
+	IsIndex bool
+}
+
+// Path addresses a value inside a Belement tree. Its text form follows jq:
+// ".info.name", ".announce-list[0][1]" or `.info["piece length"]`, with "."
+// being the root.
+type Path []PathElement
+
+func (p Path) Key(k string) Path {
+	ret := make(Path, len(p), len(p)+1)
+	copy(ret, p)
+	return append(ret, PathElement{Key: k})
+}
+
+func (p Path) Index(i int) Path {
+	ret := make(Path, len(p), len(p)+1)
+	copy(ret, p)
+	return append(ret, PathElement{Index: i, IsIndex: true})
+}
+
+func (p Path) String() string {
+	if len(p) == 0 {
+		return "."
+	}
+	b := strings.Builder{}
+	for _, e := range p {
+		switch {
+		case e.IsIndex:
+			b.WriteString("[" + strconv.Itoa(e.Index) + "]")
+		case isSimpleKey(e.Key):
+			b.WriteString("." + e.Key)
+		default:
+			b.WriteString("[" + strconv.Quote(e.Key) + "]")
+		}
+	}
+	return b.String()
+}
+
+func isSimpleKey(k string) bool {
+	if len(k) == 0 {
+		return false
+	}
+	for i := 0; i < len(k); i++ {
+		c := k[i]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+func ParsePath(s string) (Path, error) {
+	p := Path{}
+	rest := s
+	if rest == "." {
+		return p, nil
+	}
+
+	for len(rest) > 0 {
+		switch rest[0] {
+		case '.':
+			end := 1
+			for end < len(rest) && rest[end] != '.' && rest[end] != '[' {
+				end++
+			}
+			key := rest[1:end]
+			if !isSimpleKey(key) {
+				return nil, BencodeError{msg: fmt.Sprintf("Invalid path %q: bad key %q, quote it as [\"...\"]", s, key)}
+			}
+			p = append(p, PathElement{Key: key})
+			rest = rest[end:]
+		case '[':
+			if len(rest) > 1 && rest[1] == '"' {
+				// find the closing quote, skipping escaped characters
+				end := 2
+				for end < len(rest) && rest[end] != '"' {
+					if rest[end] == '\\' {
+						end++
+					}
+					end++
+				}
+				if end+1 >= len(rest) || rest[end+1] != ']' {
+					return nil, BencodeError{msg: fmt.Sprintf("Invalid path %q: unterminated key", s)}
+				}
+				key, err := strconv.Unquote(rest[1 : end+1])
+				if err != nil {
+					return nil, BencodeError{msg: fmt.Sprintf("Invalid path %q: %s", s, err.Error())}
+				}
+				p = append(p, PathElement{Key: key})
+				rest = rest[end+2:]
+			} else {
+				end := strings.IndexByte(rest, ']')
+				if end == -1 {
+					return nil, BencodeError{msg: fmt.Sprintf("Invalid path %q: missing ]", s)}
+				}
+				i, err := strconv.Atoi(rest[1:end])
+				if err != nil || i < 0 {
+					return nil, BencodeError{msg: fmt.Sprintf("Invalid path %q: bad index %q", s, rest[1:end])}
+				}
+				p = append(p, PathElement{Index: i, IsIndex: true})
+				rest = rest[end+1:]
+			}
+		default:
+			if len(p) != 0 {
+				return nil, BencodeError{msg: fmt.Sprintf("Invalid path %q: unexpected %q", s, rest[0])}
+			}
+			rest = "." + rest // leading dot is optional
+		}
+	}
+	return p, nil
+}
+
+func (v Belement) Get(p Path) (Belement, error) {
+	var err error
+	for i, e := range p {
+		if e.IsIndex {
+			v, err = v.GetListValue(e.Index)
+		} else {
+			v, err = v.GetDictValue(e.Key)
+		}
+		if err != nil {
+			return InvalidBelement, BencodeError{msg: fmt.Sprintf("%s: %s", p[:i+1], err.Error())}
+		}
+	}
+	return v, nil
+}
+
+// Set returns a copy of v with the value at p replaced. Dict keys are added
+// when missing and a list index equal to the list length appends. Only the
+// containers along p are copied; v itself is not modified.
+func (v Belement) Set(p Path, value Belement) (Belement, error) {
+	return v.update(p, Path{}, &value)
+}
+
+// Delete returns a copy of v without the dict key or list element at p.
+func (v Belement) Delete(p Path) (Belement, error) {
+	if len(p) == 0 {
+		return InvalidBelement, BencodeError{msg: "Cannot delete the root element"}
+	}
+	return v.update(p, Path{}, nil)
+}
+
+func (v Belement) update(p Path, at Path, value *Belement) (Belement, error) {
+	if len(p) == 0 {
+		return *value, nil
+	}
+	e := p[0]
+	at = append(at[:len(at):len(at)], e)
+
+	if e.IsIndex {
+		l, err := v.GetList()
+		if err != nil {
+			return InvalidBelement, BencodeError{msg: fmt.Sprintf("%s: %s", at, err.Error())}
+		}
+		if e.Index < 0 || e.Index > len(l) || (e.Index == len(l) && (value == nil || len(p) > 1)) {
+			return InvalidBelement, BencodeError{msg: fmt.Sprintf("%s: Index %d out of range", at, e.Index)}
+		}
+
+		x := make([]Belement, 0, len(l)+1)
+		x = append(x, l...)
+		switch {
+		case len(p) == 1 && value == nil:
+			x = append(x[:e.Index], x[e.Index+1:]...)
+		case e.Index == len(l):
+			x = append(x, *value)
+		default:
+			child, err := x[e.Index].update(p[1:], at, value)
+			if err != nil {
+				return InvalidBelement, err
+			}
+			x[e.Index] = child
+		}
+		return Belement{Type: TypeList, Value: x}, nil
+	}
+
+	d, err := v.GetDict()
+	if err != nil {
+		return InvalidBelement, BencodeError{msg: fmt.Sprintf("%s: %s", at, err.Error())}
+	}
+	old, ok := d[e.Key]
+	if !ok && (value == nil || len(p) > 1) {
+		return InvalidBelement, BencodeError{msg: fmt.Sprintf("%s: Key %s not found in dict", at, e.Key)}
+	}
+
+	x := make(map[string]Belement, len(d)+1)
+	for k, val := range d {
+		x[k] = val
+	}
+	if len(p) == 1 && value == nil {
+		delete(x, e.Key)
+	} else {
+		child, err := old.update(p[1:], at, value)
+		if err != nil {
+			return InvalidBelement, err
+		}
+		x[e.Key] = child
+	}
+	return Belement{Type: TypeDict, Value: x}, nil
+}
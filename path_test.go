@@ -0,0 +1,117 @@
+package bencode_test
+
+import (
+	"testing"
+
+	"github.com/deathcrafter/bencode"
+)
+
+func TestPathString(t *testing.T) {
+	for _, s := range []string{
+		".",
+		".info.name",
+		".announce-list[0][1]",
+		`.info["piece length"]`,
+		`["added.f"]`,
+		`.x["\xff"][2]`,
+	} {
+		p, err := bencode.ParsePath(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.String() != s {
+			t.Fatalf("Expected %s, got %s", s, p.String())
+		}
+	}
+
+	p, err := bencode.ParsePath("info.name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.String() != ".info.name" {
+		t.Fatalf("Expected %s, got %s", ".info.name", p.String())
+	}
+}
+
+func TestPathInvalid(t *testing.T) {
+	for _, s := range []string{
+		"..a",
+		".a b",
+		"[x]",
+		"[-1]",
+		"[0",
+		`["abc]`,
+		"[0]x",
+	} {
+		if _, err := bencode.ParsePath(s); err == nil {
+			t.Fatalf("Expected error for %s, got nil", s)
+		} else {
+			t.Log(err)
+		}
+	}
+}
+
+func TestBelementGetSet(t *testing.T) {
+	b, err := bencode.Decode([]byte("d4:infod4:name3:abce4:listli1ei2eee"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := b.Get(bencode.Path{}.Key("info").Key("name"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := v.GetString(); s != "abc" {
+		t.Fatalf("Expected %s, got %s", "abc", s)
+	}
+
+	n, err := b.Set(bencode.Path{}.Key("info").Key("name"), bencode.Belement{Type: bencode.TypeString, Value: "xyz"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err = n.Set(bencode.Path{}.Key("list").Index(2), bencode.Belement{Type: bencode.TypeInt, Value: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err = n.Delete(bencode.Path{}.Key("list").Index(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := n.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(e) != "d4:infod4:name3:xyze4:listli2ei3eee" {
+		t.Fatalf("Expected %s, got %s", "d4:infod4:name3:xyze4:listli2ei3eee", string(e))
+	}
+
+	// the original tree is left untouched
+	e, _ = b.Encode()
+	if string(e) != "d4:infod4:name3:abce4:listli1ei2eee" {
+		t.Fatalf("Original was modified: %s", string(e))
+	}
+}
+
+func TestBelementSetInvalid(t *testing.T) {
+	b, err := bencode.Decode([]byte("d4:listli1eee"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range []bencode.Path{
+		bencode.Path{}.Key("list").Index(5),
+		bencode.Path{}.Key("missing").Key("x"),
+		bencode.Path{}.Key("list").Key("x"),
+	} {
+		if _, err := b.Set(p, bencode.Belement{Type: bencode.TypeInt, Value: 1}); err == nil {
+			t.Fatalf("Expected error for %s, got nil", p)
+		} else {
+			t.Log(err)
+		}
+	}
+
+	if _, err := b.Get(bencode.Path{}.Key("list").Index(1)); err == nil {
+		t.Fatal("Expected error, got nil")
+	}
+}
@@ -0,0 +1,157 @@
+// Package pex encodes and decodes ut_pex peer exchange messages (BEP 11).
+package pex
+
+import (
+	"fmt"
+	"net/netip"
+
+	"github.com/deathcrafter/bencode"
+	"github.com/deathcrafter/bencode/compact"
+)
+
+type Flags byte
+
+const (
+	FlagEncryption Flags = 0x01
+	FlagSeed       Flags = 0x02
+	FlagUTP        Flags = 0x04
+	FlagHolepunch  Flags = 0x08
+	FlagReachable  Flags = 0x10
+)
+
+func (f Flags) Has(flag Flags) bool {
+	return f&flag == flag
+}
+
+type Peer struct {
+	Addr  netip.AddrPort
+	Flags Flags
+}
+
+type Message struct {
+	Added    []Peer
+	Dropped  []netip.AddrPort
+	Added6   []Peer
+	Dropped6 []netip.AddrPort
+}
+
+func Decode(data []byte) (Message, error) {
+	m := Message{}
+
+	b, err := bencode.Decode(data)
+	if err != nil {
+		return m, err
+	}
+	d, err := b.GetDict()
+	if err != nil {
+		return m, fmt.Errorf("pex: message: %w", err)
+	}
+
+	if m.Added, err = decodeAdded(d, "added", compact.UnpackPeers); err != nil {
+		return m, err
+	}
+	if m.Added6, err = decodeAdded(d, "added6", compact.UnpackPeers6); err != nil {
+		return m, err
+	}
+	if m.Dropped, err = decodeDropped(d, "dropped", compact.UnpackPeers); err != nil {
+		return m, err
+	}
+	if m.Dropped6, err = decodeDropped(d, "dropped6", compact.UnpackPeers6); err != nil {
+		return m, err
+	}
+
+	return m, nil
+}
+
+func (m Message) Encode() ([]byte, error) {
+	d := make(map[string]interface{})
+
+	if err := encodeAdded(d, "added", m.Added, compact.PackPeers); err != nil {
+		return nil, err
+	}
+	if err := encodeAdded(d, "added6", m.Added6, compact.PackPeers6); err != nil {
+		return nil, err
+	}
+	if err := encodeDropped(d, "dropped", m.Dropped, compact.PackPeers); err != nil {
+		return nil, err
+	}
+	if err := encodeDropped(d, "dropped6", m.Dropped6, compact.PackPeers6); err != nil {
+		return nil, err
+	}
+
+	return bencode.EncodeDict(d)
+}
+
+func decodeAdded(d map[string]bencode.Belement, key string, unpack func(string) ([]netip.AddrPort, error)) ([]Peer, error) {
+	addrs, err := decodeDropped(d, key, unpack)
+	if err != nil || addrs == nil {
+		return nil, err
+	}
+
+	flags := ""
+	if v, ok := d[key+".f"]; ok {
+		if flags, err = v.GetString(); err != nil {
+			return nil, fmt.Errorf("pex: %s.f: %w", key, err)
+		}
+		if len(flags) != len(addrs) {
+			return nil, fmt.Errorf("pex: %s.f has %d flags for %d peers", key, len(flags), len(addrs))
+		}
+	}
+
+	peers := make([]Peer, 0, len(addrs))
+	for i, a := range addrs {
+		p := Peer{Addr: a}
+		if flags != "" {
+			p.Flags = Flags(flags[i])
+		}
+		peers = append(peers, p)
+	}
+	return peers, nil
+}
+
+func decodeDropped(d map[string]bencode.Belement, key string, unpack func(string) ([]netip.AddrPort, error)) ([]netip.AddrPort, error) {
+	v, ok := d[key]
+	if !ok {
+		return nil, nil
+	}
+	s, err := v.GetString()
+	if err != nil {
+		return nil, fmt.Errorf("pex: %s: %w", key, err)
+	}
+	addrs, err := unpack(s)
+	if err != nil {
+		return nil, fmt.Errorf("pex: %s: %w", key, err)
+	}
+	return addrs, nil
+}
+
+func encodeAdded(d map[string]interface{}, key string, peers []Peer, pack func([]netip.AddrPort) (string, error)) error {
+	if len(peers) == 0 {
+		return nil
+	}
+
+	addrs := make([]netip.AddrPort, 0, len(peers))
+	flags := make([]byte, 0, len(peers))
+	for _, p := range peers {
+		addrs = append(addrs, p.Addr)
+		flags = append(flags, byte(p.Flags))
+	}
+
+	if err := encodeDropped(d, key, addrs, pack); err != nil {
+		return err
+	}
+	d[key+".f"] = string(flags)
+	return nil
+}
+
+func encodeDropped(d map[string]interface{}, key string, addrs []netip.AddrPort, pack func([]netip.AddrPort) (string, error)) error {
+	if len(addrs) == 0 {
+		return nil
+	}
+	s, err := pack(addrs)
+	if err != nil {
+		return fmt.Errorf("pex: %s: %w", key, err)
+	}
+	d[key] = s
+	return nil
+}
@@ -0,0 +1,70 @@
+package pex_test
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/deathcrafter/bencode/pex"
+)
+
+func TestRoundTrip(t *testing.T) {
+	m := pex.Message{
+		Added: []pex.Peer{
+			{Addr: netip.MustParseAddrPort("10.0.0.1:6881"), Flags: pex.FlagSeed | pex.FlagUTP},
+		},
+		Dropped: []netip.AddrPort{netip.MustParseAddrPort("10.0.0.2:6881")},
+		Added6: []pex.Peer{
+			{Addr: netip.MustParseAddrPort("[2001:db8::1]:6881"), Flags: pex.FlagEncryption},
+		},
+	}
+	e, err := m.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "d5:added6:\x0a\x00\x00\x01\x1a\xe17:added.f1:\x066:added618:\x20\x01\x0d\xb8\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x01\x1a\xe1" +
+		"8:added6.f1:\x017:dropped6:\x0a\x00\x00\x02\x1a\xe1e"
+	if string(e) != expected {
+		t.Fatalf("Expected %q, got %q", expected, string(e))
+	}
+
+	d, err := pex.Decode(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Added) != 1 || d.Added[0] != m.Added[0] || !d.Added[0].Flags.Has(pex.FlagSeed) {
+		t.Fatalf("Expected %v, got %v", m.Added, d.Added)
+	}
+	if len(d.Added6) != 1 || d.Added6[0] != m.Added6[0] {
+		t.Fatalf("Expected %v, got %v", m.Added6, d.Added6)
+	}
+	if len(d.Dropped) != 1 || d.Dropped[0] != m.Dropped[0] || d.Dropped6 != nil {
+		t.Fatalf("Expected %v, got %v", m.Dropped, d.Dropped)
+	}
+}
+
+func TestDecodeWithoutFlags(t *testing.T) {
+	m, err := pex.Decode([]byte("d5:added6:\x0a\x00\x00\x01\x1a\xe1e"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Added) != 1 || m.Added[0].Flags != 0 {
+		t.Fatalf("Unexpected peers %v", m.Added)
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	for _, data := range []string{
+		"le",
+		"d5:addedi1ee",
+		"d5:added5:abcdee",
+		"d5:added6:\x0a\x00\x00\x01\x1a\xe17:added.f2:\x00\x00e",
+		"d8:dropped66:\x0a\x00\x00\x01\x1a\xe1e",
+	} {
+		if _, err := pex.Decode([]byte(data)); err == nil {
+			t.Fatalf("Expected error for %q, got nil", data)
+		} else {
+			t.Log(err)
+		}
+	}
+}
@@ -0,0 +1,409 @@
+// Package resume reads and writes libtorrent style .fastresume files.
+//
+// Keys that are not modelled by FastResume, such as the qBt-* keys written by
+// qBittorrent, are kept in Extra and written back unchanged. Modelled keys
+// are written when they were read or hold a value other than the zero one,
+// so a file that is decoded and encoded again keeps the same keys.
+package resume
+
+import (
+	"fmt"
+	"io"
+	"net/netip"
+
+	"github.com/deathcrafter/bencode"
+	"github.com/deathcrafter/bencode/compact"
+	"github.com/deathcrafter/bencode/internal/dictutil"
+)
+
+const (
+	FileFormat  = "libtorrent resume file"
+	FileVersion = 1
+)
+
+// Bits of the per-piece bytes in FastResume.Pieces.
+const (
+	PieceHave     = 0x01
+	PieceVerified = 0x02
+)
+
+type UnfinishedPiece struct {
+	Piece   int
+	Bitmask string // one bit per 16 KiB block, most significant bit first
+	Adler32 int
+}
+
+type FastResume struct {
+	Version   int // file-version, FileVersion when 0
+	InfoHash  [20]byte
+	InfoHash2 string // v2 info hash, 32 bytes when present
+	Name      string
+	SavePath  string
+
+	TotalUploaded     int
+	TotalDownloaded   int
+	ActiveTime        int
+	FinishedTime      int
+	SeedingTime       int
+	AddedTime         int
+	CompletedTime     int
+	LastSeenComplete  int
+	LastDownload      int
+	LastUpload        int
+	NumComplete       int
+	NumIncomplete     int
+	NumDownloaded     int
+	UploadRateLimit   int
+	DownloadRateLimit int
+	MaxConnections    int
+	MaxUploads        int
+
+	Paused             bool
+	AutoManaged        bool
+	SeedMode           bool
+	SuperSeeding       bool
+	SequentialDownload bool
+	StopWhenReady      bool
+	ShareMode          bool
+	UploadMode         bool
+	ApplyIPFilter      bool
+	DisableDHT         bool
+	DisableLSD         bool
+	DisablePEX         bool
+
+	Pieces        string // one byte per piece, see PieceHave and PieceVerified
+	PiecePriority string // one byte per piece
+	FilePriority  []int
+	MappedFiles   []string
+	Unfinished    []UnfinishedPiece
+
+	Trackers  [][]string // tiers
+	URLSeeds  []string
+	HTTPSeeds []string
+
+	Peers        []netip.AddrPort
+	Peers6       []netip.AddrPort
+	BannedPeers  []netip.AddrPort
+	BannedPeers6 []netip.AddrPort
+
+	Info  bencode.Belement // embedded info dict, TypeInvalid when absent
+	Extra map[string]bencode.Belement
+
+	present map[string]bool // keys read by Decode
+	adler32 map[int]bool    // unfinished pieces read with an adler32
+}
+
+func (f *FastResume) HasPiece(index int) bool {
+	return index >= 0 && index < len(f.Pieces) && f.Pieces[index]&PieceHave != 0
+}
+
+func (f *FastResume) intFields() map[string]*int {
+	return map[string]*int{
+		"total_uploaded":      &f.TotalUploaded,
+		"total_downloaded":    &f.TotalDownloaded,
+		"active_time":         &f.ActiveTime,
+		"finished_time":       &f.FinishedTime,
+		"seeding_time":        &f.SeedingTime,
+		"added_time":          &f.AddedTime,
+		"completed_time":      &f.CompletedTime,
+		"last_seen_complete":  &f.LastSeenComplete,
+		"last_download":       &f.LastDownload,
+		"last_upload":         &f.LastUpload,
+		"num_complete":        &f.NumComplete,
+		"num_incomplete":      &f.NumIncomplete,
+		"num_downloaded":      &f.NumDownloaded,
+		"upload_rate_limit":   &f.UploadRateLimit,
+		"download_rate_limit": &f.DownloadRateLimit,
+		"max_connections":     &f.MaxConnections,
+		"max_uploads":         &f.MaxUploads,
+	}
+}
+
+func (f *FastResume) boolFields() map[string]*bool {
+	return map[string]*bool{
+		"paused":              &f.Paused,
+		"auto_managed":        &f.AutoManaged,
+		"seed_mode":           &f.SeedMode,
+		"super_seeding":       &f.SuperSeeding,
+		"sequential_download": &f.SequentialDownload,
+		"stop_when_ready":     &f.StopWhenReady,
+		"share_mode":          &f.ShareMode,
+		"upload_mode":         &f.UploadMode,
+		"apply_ip_filter":     &f.ApplyIPFilter,
+		"disable_dht":         &f.DisableDHT,
+		"disable_lsd":         &f.DisableLSD,
+		"disable_pex":         &f.DisablePEX,
+	}
+}
+
+func (f *FastResume) stringFields() map[string]*string {
+	return map[string]*string{
+		"info-hash2":     &f.InfoHash2,
+		"name":           &f.Name,
+		"save_path":      &f.SavePath,
+		"pieces":         &f.Pieces,
+		"piece_priority": &f.PiecePriority,
+	}
+}
+
+func (f *FastResume) stringListFields() map[string]*[]string {
+	return map[string]*[]string{
+		"mapped_files": &f.MappedFiles,
+		"url-list":     &f.URLSeeds,
+		"httpseeds":    &f.HTTPSeeds,
+	}
+}
+
+type peerField struct {
+	peers *[]netip.AddrPort
+	v6    bool
+}
+
+func (f *FastResume) peerFields() map[string]peerField {
+	return map[string]peerField{
+		"peers":         {&f.Peers, false},
+		"peers6":        {&f.Peers6, true},
+		"banned_peers":  {&f.BannedPeers, false},
+		"banned_peers6": {&f.BannedPeers6, true},
+	}
+}
+
+func Read(r io.Reader) (FastResume, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return FastResume{}, err
+	}
+	return Decode(data)
+}
+
+func Decode(data []byte) (FastResume, error) {
+	f := FastResume{Extra: make(map[string]bencode.Belement), present: make(map[string]bool)}
+
+	b, err := bencode.Decode(data)
+	if err != nil {
+		return f, err
+	}
+	d, err := b.GetDict()
+	if err != nil {
+		return f, fmt.Errorf("resume: %w", err)
+	}
+
+	format, err := b.GetDictString("file-format")
+	if err != nil {
+		return f, fmt.Errorf("resume: file-format: %w", err)
+	}
+	if format != FileFormat {
+		return f, fmt.Errorf("resume: unsupported file-format %q", format)
+	}
+
+	hash, err := b.GetDictString("info-hash")
+	if err != nil {
+		return f, fmt.Errorf("resume: info-hash: %w", err)
+	}
+	if len(hash) != len(f.InfoHash) {
+		return f, fmt.Errorf("resume: info-hash has length %d, expected %d", len(hash), len(f.InfoHash))
+	}
+	copy(f.InfoHash[:], hash)
+
+	ints, bools, strs, lists, peers := f.intFields(), f.boolFields(), f.stringFields(), f.stringListFields(), f.peerFields()
+
+	for k, v := range d {
+		var err error
+		f.present[k] = true
+		switch {
+		case k == "file-format" || k == "info-hash":
+		case k == "file-version":
+			f.Version, err = v.GetInt()
+		case ints[k] != nil:
+			*ints[k], err = v.GetInt()
+		case bools[k] != nil:
+			var i int
+			i, err = v.GetInt()
+			*bools[k] = i != 0
+		case strs[k] != nil:
+			*strs[k], err = v.GetString()
+		case lists[k] != nil:
+			*lists[k], err = v.GetStringList()
+		case peers[k].peers != nil:
+			var s string
+			if s, err = v.GetString(); err == nil {
+				if peers[k].v6 {
+					*peers[k].peers, err = compact.UnpackPeers6(s)
+				} else {
+					*peers[k].peers, err = compact.UnpackPeers(s)
+				}
+			}
+		case k == "file_priority":
+			f.FilePriority, err = v.GetIntList()
+		case k == "trackers":
+			f.Trackers, err = decodeTiers(v)
+		case k == "unfinished":
+			f.Unfinished, f.adler32, err = decodeUnfinished(v)
+		case k == "info":
+			_, err = v.GetDict()
+			f.Info = v
+		default:
+			f.Extra[k] = v
+		}
+		if err != nil {
+			return f, fmt.Errorf("resume: %s: %w", k, err)
+		}
+	}
+
+	return f, nil
+}
+
+func (f FastResume) WriteTo(w io.Writer) (int64, error) {
+	e, err := f.Encode()
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(e)
+	return int64(n), err
+}
+
+func (f FastResume) Encode() ([]byte, error) {
+	d := make(map[string]interface{}, len(f.Extra)+32)
+	for k, v := range f.Extra {
+		d[k] = v
+	}
+
+	d["file-format"] = FileFormat
+	switch {
+	case f.Version != 0:
+		d["file-version"] = f.Version
+	case f.present == nil || f.present["file-version"]:
+		d["file-version"] = FileVersion
+	}
+	d["info-hash"] = string(f.InfoHash[:])
+
+	for k, v := range f.intFields() {
+		if *v != 0 || f.present[k] {
+			d[k] = *v
+		}
+	}
+	for k, v := range f.boolFields() {
+		switch {
+		case *v:
+			d[k] = 1
+		case f.present[k]:
+			d[k] = 0
+		}
+	}
+	for k, v := range f.stringFields() {
+		if *v != "" || f.present[k] {
+			d[k] = *v
+		}
+	}
+	for k, v := range f.stringListFields() {
+		if *v != nil {
+			d[k] = stringList(*v)
+		}
+	}
+	for k, v := range f.peerFields() {
+		if len(*v.peers) == 0 && !f.present[k] {
+			continue
+		}
+		var s string
+		var err error
+		if v.v6 {
+			s, err = compact.PackPeers6(*v.peers)
+		} else {
+			s, err = compact.PackPeers(*v.peers)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("resume: %s: %w", k, err)
+		}
+		d[k] = s
+	}
+
+	if f.FilePriority != nil {
+		l := make([]interface{}, 0, len(f.FilePriority))
+		for _, p := range f.FilePriority {
+			l = append(l, p)
+		}
+		d["file_priority"] = l
+	}
+	if f.Trackers != nil {
+		tiers := make([]interface{}, 0, len(f.Trackers))
+		for _, tier := range f.Trackers {
+			tiers = append(tiers, stringList(tier))
+		}
+		d["trackers"] = tiers
+	}
+	if f.Unfinished != nil {
+		l := make([]interface{}, 0, len(f.Unfinished))
+		for _, u := range f.Unfinished {
+			p := map[string]interface{}{
+				"piece":   u.Piece,
+				"bitmask": u.Bitmask,
+			}
+			if u.Adler32 != 0 || f.adler32[u.Piece] {
+				p["adler32"] = u.Adler32
+			}
+			l = append(l, p)
+		}
+		d["unfinished"] = l
+	}
+	if f.Info.Type != bencode.TypeInvalid {
+		d["info"] = f.Info
+	}
+
+	return bencode.EncodeDict(d)
+}
+
+func decodeTiers(v bencode.Belement) ([][]string, error) {
+	l, err := v.GetList()
+	if err != nil {
+		return nil, err
+	}
+	tiers := make([][]string, 0, len(l))
+	for _, tier := range l {
+		urls, err := tier.GetStringList()
+		if err != nil {
+			return nil, err
+		}
+		tiers = append(tiers, urls)
+	}
+	return tiers, nil
+}
+
+// decodeUnfinished also returns the pieces that have an adler32.
+func decodeUnfinished(v bencode.Belement) ([]UnfinishedPiece, map[int]bool, error) {
+	l, err := v.GetList()
+	if err != nil {
+		return nil, nil, err
+	}
+	pieces := make([]UnfinishedPiece, 0, len(l))
+	adler32 := make(map[int]bool)
+	for _, p := range l {
+		u := UnfinishedPiece{}
+		if u.Piece, err = p.GetDictInt("piece"); err != nil {
+			return nil, nil, err
+		}
+		if u.Bitmask, err = p.GetDictString("bitmask"); err != nil {
+			return nil, nil, err
+		}
+		d, _ := p.GetDict()
+		a, err := opt.IntPtr(d, "adler32")
+		if err != nil {
+			return nil, nil, err
+		}
+		if a != nil {
+			u.Adler32, adler32[u.Piece] = *a, true
+		}
+		pieces = append(pieces, u)
+	}
+	return pieces, adler32, nil
+}
+
+func stringList(s []string) []interface{} {
+	l := make([]interface{}, 0, len(s))
+	for _, v := range s {
+		l = append(l, v)
+	}
+	return l
+}
+
+// opt reads the optional keys of a dict, leaving the context to Decode.
+var opt dictutil.Reader
@@ -0,0 +1,130 @@
+package resume_test
+
+import (
+	"bytes"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/deathcrafter/bencode"
+	"github.com/deathcrafter/bencode/resume"
+)
+
+func TestRoundTrip(t *testing.T) {
+	f := resume.FastResume{
+		Name:            "ubuntu.iso",
+		SavePath:        "/downloads",
+		TotalUploaded:   1 << 40,
+		TotalDownloaded: 123456,
+		AddedTime:       1700000000,
+		Paused:          true,
+		AutoManaged:     true,
+		Pieces:          "\x03\x03\x00\x01",
+		FilePriority:    []int{4, 0, 7},
+		Trackers:        [][]string{{"http://a/announce", "http://b/announce"}, {"udp://c:80"}},
+		Peers:           []netip.AddrPort{netip.MustParseAddrPort("10.0.0.1:6881")},
+		Peers6:          []netip.AddrPort{netip.MustParseAddrPort("[2001:db8::1]:6881")},
+		Unfinished:      []resume.UnfinishedPiece{{Piece: 2, Bitmask: "\xf0", Adler32: 99}},
+		Info:            bencode.Belement{Type: bencode.TypeDict, Value: map[string]bencode.Belement{"name": {Type: bencode.TypeString, Value: "ubuntu.iso"}}},
+		Extra:           map[string]bencode.Belement{"qBt-category": {Type: bencode.TypeString, Value: "linux"}},
+	}
+	copy(f.InfoHash[:], strings.Repeat("\xab", 20))
+
+	e, err := f.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := resume.Decode(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.InfoHash != f.InfoHash || d.Name != f.Name || d.TotalUploaded != f.TotalUploaded || !d.Paused || d.SeedMode {
+		t.Fatalf("Expected %+v, got %+v", f, d)
+	}
+	if !d.HasPiece(0) || d.HasPiece(2) || !d.HasPiece(3) || d.HasPiece(4) {
+		t.Fatalf("Unexpected pieces %q", d.Pieces)
+	}
+	if len(d.Trackers) != 2 || d.Trackers[0][1] != "http://b/announce" {
+		t.Fatalf("Unexpected trackers %v", d.Trackers)
+	}
+	if len(d.Peers) != 1 || d.Peers[0] != f.Peers[0] || len(d.Peers6) != 1 || d.Peers6[0] != f.Peers6[0] {
+		t.Fatalf("Unexpected peers %v %v", d.Peers, d.Peers6)
+	}
+	if len(d.Unfinished) != 1 || d.Unfinished[0] != f.Unfinished[0] {
+		t.Fatalf("Unexpected unfinished pieces %v", d.Unfinished)
+	}
+	if name, _ := d.Info.GetDictString("name"); name != "ubuntu.iso" {
+		t.Fatalf("Expected info name %s, got %s", "ubuntu.iso", name)
+	}
+	if c, _ := d.Extra["qBt-category"].GetString(); c != "linux" {
+		t.Fatalf("Expected extra key to survive, got %v", d.Extra)
+	}
+
+	buf := bytes.Buffer{}
+	if _, err := d.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), e) {
+		t.Fatalf("Expected stable encoding\n%q\n%q", e, buf.Bytes())
+	}
+}
+
+func TestDecodeEncodeKeepsBytes(t *testing.T) {
+	// zero values, an empty peer list, a file-version other than
+	// FileVersion and unfinished pieces with and without adler32, but
+	// none of the keys the file does not have
+	data := "d11:file-format22:libtorrent resume file12:file-versioni2e" +
+		"9:info-hash20:" + strings.Repeat("a", 20) +
+		"15:max_connectionsi-1e4:name0:6:pausedi0e5:peers0:12:qBt-category5:linux" +
+		"14:total_uploadedi0e10:unfinishedld7:adler32i0e7:bitmask1:\xf05:piecei1eed7:bitmask1:\x0f5:piecei2eee" +
+		"8:url-listlee"
+	f, err := resume.Decode([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Version != 2 || f.MaxConnections != -1 {
+		t.Fatalf("Unexpected resume data %+v", f)
+	}
+
+	e, err := f.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(e) != data {
+		t.Fatalf("Expected\n%q\ngot\n%q", data, e)
+	}
+
+	// a new FastResume gets the current file-version
+	if e, _ = (resume.FastResume{}).Encode(); !bytes.Contains(e, []byte("12:file-versioni1e")) {
+		t.Fatalf("Expected file-version %d in %q", resume.FileVersion, e)
+	}
+}
+
+func TestRead(t *testing.T) {
+	data := "d11:file-format22:libtorrent resume file12:file-versioni1e9:info-hash20:" + strings.Repeat("a", 20) +
+		"4:name3:foo6:pausedi1e8:url-listl8:http://xee"
+	f, err := resume.Read(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Name != "foo" || !f.Paused || len(f.URLSeeds) != 1 {
+		t.Fatalf("Unexpected resume data %+v", f)
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	for _, data := range []string{
+		"le",
+		"d11:file-format3:fooe",
+		"d11:file-format22:libtorrent resume file9:info-hash3:abce",
+		"d11:file-format22:libtorrent resume file9:info-hash20:" + strings.Repeat("a", 20) + "6:paused3:yese",
+		"d11:file-format22:libtorrent resume file9:info-hash20:" + strings.Repeat("a", 20) + "5:peers5:abcdee",
+	} {
+		if _, err := resume.Decode([]byte(data)); err == nil {
+			t.Fatalf("Expected error for %q, got nil", data)
+		} else {
+			t.Log(err)
+		}
+	}
+}
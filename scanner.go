@@ -0,0 +1,144 @@
+package bencode
+
+import (
+	"bytes"
+)
+
+// Scanner reads encoded data one element at a time without building
+// Belements. It is the decoding counterpart of the Append functions and what
+// code generated by cmd/bencodegen is written with. It accepts the same
+// elements as Decode, and Finish is needed to reject trailing data. Errors
+// are SyntaxErrors, and the scanner does not move past an element it fails
+// to read.
+type Scanner struct {
+	data []byte
+	pos  int
+}
+
+func NewScanner(data []byte) *Scanner {
+	return &Scanner{data: data}
+}
+
+// Offset returns the offset of the next element.
+func (s *Scanner) Offset() int {
+	return s.pos
+}
+
+// Peek returns the type of the next element without reading it, and
+// TypeInvalid at the end of a list, dict or the data.
+func (s *Scanner) Peek() BelementType {
+	if s.pos >= len(s.data) {
+		return TypeInvalid
+	}
+	switch c := s.data[s.pos]; {
+	case c == 'i':
+		return TypeInt
+	case c == 'l':
+		return TypeList
+	case c == 'd':
+		return TypeDict
+	case c >= '0' && c <= '9':
+		return TypeString
+	}
+	return TypeInvalid
+}
+
+// More reports whether the current list or dict has another element.
+func (s *Scanner) More() bool {
+	return s.pos < len(s.data) && s.data[s.pos] != 'e'
+}
+
+func (s *Scanner) Int() (int, error) {
+	if err := s.expect(TypeInt); err != nil {
+		return 0, err
+	}
+	end := bytes.IndexByte(s.data[s.pos:], 'e')
+	if end == -1 {
+		return 0, SyntaxError{Offset: s.pos, msg: "Invalid integer format: missing end of element"}
+	}
+	v, _, err := parseInt(s.data[s.pos+1 : s.pos+end])
+	if err != nil {
+		return 0, SyntaxError{Offset: s.pos + 1, msg: err.Error()}
+	}
+	s.pos += end + 1
+	return v, nil
+}
+
+// Bytes reads a string. The result aliases the scanned data.
+func (s *Scanner) Bytes() ([]byte, error) {
+	if err := s.expect(TypeString); err != nil {
+		return nil, err
+	}
+	b, end, err := scanner{data: s.data}.str(s.pos)
+	if err != nil {
+		return nil, err
+	}
+	s.pos = end
+	return b, nil
+}
+
+// List reads the start of a list. Its elements follow while More reports
+// true, and End reads the end of it.
+func (s *Scanner) List() error {
+	if err := s.expect(TypeList); err != nil {
+		return err
+	}
+	s.pos++
+	return nil
+}
+
+// Dict reads the start of a dict. Its keys, read with Bytes, and values
+// follow while More reports true, and End reads the end of it.
+func (s *Scanner) Dict() error {
+	if err := s.expect(TypeDict); err != nil {
+		return err
+	}
+	s.pos++
+	return nil
+}
+
+func (s *Scanner) End() error {
+	if s.pos >= len(s.data) {
+		return SyntaxError{Offset: s.pos, msg: "Unexpected end of data"}
+	}
+	if s.data[s.pos] != 'e' {
+		return SyntaxError{Offset: s.pos, msg: "Expected end of list or dict"}
+	}
+	s.pos++
+	return nil
+}
+
+// Raw reads the next element and returns its encoded bytes, which alias the
+// scanned data.
+func (s *Scanner) Raw() ([]byte, error) {
+	end, err := scanner{data: s.data}.value(s.pos)
+	if err != nil {
+		return nil, err
+	}
+	raw := s.data[s.pos:end]
+	s.pos = end
+	return raw, nil
+}
+
+func (s *Scanner) Skip() error {
+	_, err := s.Raw()
+	return err
+}
+
+// Finish reports an error unless all of the data has been read.
+func (s *Scanner) Finish() error {
+	if s.pos != len(s.data) {
+		return SyntaxError{Offset: s.pos, msg: "Trailing data after element"}
+	}
+	return nil
+}
+
+func (s *Scanner) expect(t BelementType) error {
+	if s.pos >= len(s.data) {
+		return SyntaxError{Offset: s.pos, msg: "Unexpected end of data"}
+	}
+	if s.Peek() != t {
+		return SyntaxError{Offset: s.pos, msg: "Expected " + t.String()}
+	}
+	return nil
+}
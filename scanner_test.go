@@ -0,0 +1,99 @@
+package bencode_test
+
+import (
+	"testing"
+
+	"github.com/deathcrafter/bencode"
+)
+
+func TestScanner(t *testing.T) {
+	s := bencode.NewScanner([]byte("d1:ai-3e1:bl1:xi0ee1:cd1:di1eee"))
+	if s.Peek() != bencode.TypeDict {
+		t.Fatalf("Expected dict, got %s", s.Peek())
+	}
+	if err := s.Dict(); err != nil {
+		t.Fatal(err)
+	}
+
+	var keys []string
+	for s.More() {
+		k, err := s.Bytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, string(k))
+
+		switch string(k) {
+		case "a":
+			if v, err := s.Int(); err != nil || v != -3 {
+				t.Fatalf("Expected -3, got %d, %v", v, err)
+			}
+		case "b":
+			if err := s.List(); err != nil {
+				t.Fatal(err)
+			}
+			if x, err := s.Bytes(); err != nil || string(x) != "x" {
+				t.Fatalf("Expected x, got %q, %v", x, err)
+			}
+			if err := s.Skip(); err != nil {
+				t.Fatal(err)
+			}
+			if s.More() {
+				t.Fatal("Expected end of list")
+			}
+			if err := s.End(); err != nil {
+				t.Fatal(err)
+			}
+		case "c":
+			raw, err := s.Raw()
+			if err != nil || string(raw) != "d1:di1ee" {
+				t.Fatalf("Expected raw dict, got %q, %v", raw, err)
+			}
+		}
+	}
+	if err := s.End(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Finish(); err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 3 {
+		t.Fatalf("Expected 3 keys, got %q", keys)
+	}
+}
+
+func TestScannerErrors(t *testing.T) {
+	cases := []struct {
+		data   string
+		read   func(s *bencode.Scanner) error
+		offset int
+	}{
+		{"3:abc", func(s *bencode.Scanner) error { _, err := s.Int(); return err }, 0},
+		{"i0x3e", func(s *bencode.Scanner) error { _, err := s.Int(); return err }, 1},
+		{"i1", func(s *bencode.Scanner) error { _, err := s.Int(); return err }, 0},
+		{"5:abc", func(s *bencode.Scanner) error { _, err := s.Bytes(); return err }, 0},
+		{"le", func(s *bencode.Scanner) error { return s.Dict() }, 0},
+		{"", func(s *bencode.Scanner) error { return s.List() }, 0},
+		{"l", func(s *bencode.Scanner) error { s.List(); return s.End() }, 1},
+		{"li1e", func(s *bencode.Scanner) error { s.List(); return s.End() }, 1},
+		{"i1ei2e", func(s *bencode.Scanner) error { s.Int(); return s.Finish() }, 3},
+	}
+
+	// a failed read leaves the scanner where it was
+	s := bencode.NewScanner([]byte("i0x3e"))
+	if _, err := s.Int(); err == nil || s.Offset() != 0 {
+		t.Fatalf("Expected error at offset 0, got %v at %d", err, s.Offset())
+	}
+
+	for _, c := range cases {
+		s := bencode.NewScanner([]byte(c.data))
+		err := c.read(s)
+		serr, ok := err.(bencode.SyntaxError)
+		if !ok {
+			t.Fatalf("%q: expected SyntaxError, got %v", c.data, err)
+		}
+		if serr.Offset != c.offset {
+			t.Fatalf("%q: expected offset %d, got %d", c.data, c.offset, serr.Offset)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package bencode
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Range is an inclusive bound used by Schema.
+type Range struct {
+	Min int
+	Max int
+}
+
+// Schema describes the expected shape of a Belement. Zero fields impose no
+// constraint, so Schema{} accepts any value.
+type Schema struct {
+	Type BelementType // TypeInvalid accepts any type
+
+	Int *Range // value of integers
+	Len *Range // byte length of strings, element count of lists and dicts
+
+	Elem *Schema // every list element
+
+	Keys     map[string]*Schema // known dict keys
+	Required []string
+	Values   *Schema // dict keys not in Keys, when set
+	Closed   bool    // reject dict keys not in Keys
+
+	Check func(v Belement) error // extra check run after the others pass
+}
+
+type Violation struct {
+	Path Path
+	Msg  string
+}
+
+func (v Violation) String() string {
+	return v.Path.String() + ": " + v.Msg
+}
+
+// ValidationError lists every violation found by Schema.Validate.
+type ValidationError []Violation
+
+func (e ValidationError) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, v := range e {
+		msgs = append(msgs, v.String())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Validate checks v against s and returns a ValidationError holding all
+// violations, or nil.
+func (s *Schema) Validate(v Belement) error {
+	violations := s.validate(v, Path{}, make(ValidationError, 0))
+	if len(violations) == 0 {
+		return nil
+	}
+	return violations
+}
+
+func (s *Schema) validate(v Belement, p Path, violations ValidationError) ValidationError {
+	if s == nil {
+		return violations
+	}
+	fail := func(format string, args ...interface{}) {
+		violations = append(violations, Violation{Path: p, Msg: fmt.Sprintf(format, args...)})
+	}
+
+	if v.Type == TypeInvalid {
+		fail("invalid value")
+		return violations
+	}
+	if s.Type != TypeInvalid && v.Type != s.Type {
+		fail("expected %s, got %s", s.Type, v.Type)
+		return violations
+	}
+	before := len(violations)
+
+	switch v.Type {
+	case TypeInt:
+		i, _ := v.GetInt()
+		if s.Int != nil && (i < s.Int.Min || i > s.Int.Max) {
+			fail("value %d out of range [%d, %d]", i, s.Int.Min, s.Int.Max)
+		}
+	case TypeString:
+		str, _ := v.GetString()
+		if s.Len != nil && (len(str) < s.Len.Min || len(str) > s.Len.Max) {
+			fail("length %d out of range [%d, %d]", len(str), s.Len.Min, s.Len.Max)
+		}
+	case TypeList:
+		l, _ := v.GetList()
+		if s.Len != nil && (len(l) < s.Len.Min || len(l) > s.Len.Max) {
+			fail("length %d out of range [%d, %d]", len(l), s.Len.Min, s.Len.Max)
+		}
+		for i, e := range l {
+			violations = s.Elem.validate(e, p.Index(i), violations)
+		}
+	case TypeDict:
+		d, _ := v.GetDict()
+		if s.Len != nil && (len(d) < s.Len.Min || len(d) > s.Len.Max) {
+			fail("length %d out of range [%d, %d]", len(d), s.Len.Min, s.Len.Max)
+		}
+		for _, k := range s.Required {
+			if _, ok := d[k]; !ok {
+				violations = append(violations, Violation{Path: p.Key(k), Msg: "missing required key"})
+			}
+		}
+
+		for _, k := range sortedKeys(d) {
+			if ks, ok := s.Keys[k]; ok {
+				violations = ks.validate(d[k], p.Key(k), violations)
+			} else if s.Closed {
+				violations = append(violations, Violation{Path: p.Key(k), Msg: "unknown key"})
+			} else {
+				violations = s.Values.validate(d[k], p.Key(k), violations)
+			}
+		}
+	}
+
+	if s.Check != nil && len(violations) == before {
+		if err := s.Check(v); err != nil {
+			fail("%s", err.Error())
+		}
+	}
+	return violations
+}
@@ -0,0 +1,93 @@
+package bencode_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/deathcrafter/bencode"
+)
+
+var torrentSchema = &bencode.Schema{
+	Type:     bencode.TypeDict,
+	Required: []string{"announce", "info"},
+	Keys: map[string]*bencode.Schema{
+		"announce": {Type: bencode.TypeString, Len: &bencode.Range{Min: 1, Max: 1024}},
+		"announce-list": {
+			Type: bencode.TypeList,
+			Elem: &bencode.Schema{Type: bencode.TypeList, Elem: &bencode.Schema{Type: bencode.TypeString}},
+		},
+		"info": {
+			Type:     bencode.TypeDict,
+			Required: []string{"name", "piece length", "pieces"},
+			Keys: map[string]*bencode.Schema{
+				"name":         {Type: bencode.TypeString},
+				"length":       {Type: bencode.TypeInt, Int: &bencode.Range{Min: 0, Max: 1 << 50}},
+				"piece length": {Type: bencode.TypeInt, Int: &bencode.Range{Min: 16384, Max: 1 << 26}},
+				"pieces": {Type: bencode.TypeString, Check: func(v bencode.Belement) error {
+					if s, _ := v.GetString(); len(s)%20 != 0 {
+						return errors.New("length is not a multiple of 20")
+					}
+					return nil
+				}},
+			},
+		},
+	},
+}
+
+func TestSchemaValid(t *testing.T) {
+	b, err := bencode.Decode([]byte("d8:announce3:url13:announce-listll1:aee4:infod6:lengthi5e4:name1:x12:piece lengthi16384e6:pieces20:aaaaaaaaaaaaaaaaaaaaee"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := torrentSchema.Validate(b); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSchemaViolations(t *testing.T) {
+	b, err := bencode.Decode([]byte("d8:announce0:13:announce-listli1ee4:infod6:lengthi-1e12:piece lengthi1e6:pieces3:abcee"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = torrentSchema.Validate(b)
+	violations, ok := err.(bencode.ValidationError)
+	if !ok {
+		t.Fatalf("Expected ValidationError, got %T", err)
+	}
+
+	expected := []string{
+		".announce: length 0 out of range [1, 1024]",
+		".announce-list[0]: expected list, got int",
+		".info.name: missing required key",
+		".info.length: value -1 out of range [0, 1125899906842624]",
+		".info[\"piece length\"]: value 1 out of range [16384, 67108864]",
+		".info.pieces: length is not a multiple of 20",
+	}
+	if len(violations) != len(expected) {
+		t.Fatalf("Expected %d violations, got %d: %s", len(expected), len(violations), err)
+	}
+	for i, v := range violations {
+		if v.String() != expected[i] {
+			t.Fatalf("Expected %s, got %s", expected[i], v.String())
+		}
+	}
+	t.Log(err)
+}
+
+func TestSchemaClosedAndValues(t *testing.T) {
+	s := &bencode.Schema{
+		Type:   bencode.TypeDict,
+		Keys:   map[string]*bencode.Schema{"a": {Type: bencode.TypeInt}},
+		Closed: true,
+	}
+	b, _ := bencode.Decode([]byte("d1:ai1e1:bi2ee"))
+	if err := s.Validate(b); err == nil || err.Error() != ".b: unknown key" {
+		t.Fatalf("Expected unknown key violation, got %v", err)
+	}
+
+	s = &bencode.Schema{Type: bencode.TypeDict, Values: &bencode.Schema{Type: bencode.TypeString}}
+	if err := s.Validate(b); err == nil || err.Error() != ".a: expected string, got int; .b: expected string, got int" {
+		t.Fatalf("Unexpected result %v", err)
+	}
+}
@@ -0,0 +1,309 @@
+// Package tracker encodes and decodes HTTP tracker announce and scrape
+// responses (BEP 3, BEP 7, BEP 23, BEP 48).
+package tracker
+
+import (
+	"fmt"
+	"net/netip"
+
+	"github.com/deathcrafter/bencode"
+	"github.com/deathcrafter/bencode/compact"
+	"github.com/deathcrafter/bencode/internal/dictutil"
+)
+
+type Peer struct {
+	ID   string // empty for compact peers and no_peer_id responses
+	Addr netip.AddrPort
+	// Host is the ip of a dict-form peer that gave a DNS name, which BEP 3
+	// allows, instead of an address. Addr then holds only the port.
+	Host string
+}
+
+type AnnounceResponse struct {
+	FailureReason  string
+	WarningMessage string
+	Interval       int
+	MinInterval    int
+	TrackerID      string
+	Complete       int
+	Incomplete     int
+	Peers          []Peer
+	Peers6         []Peer
+
+	// Compact selects the BEP 23 string form for Peers when encoding.
+	// Decoding sets it when the tracker sent compact peers.
+	Compact bool
+}
+
+type ScrapeFile struct {
+	Complete   int
+	Downloaded int
+	Incomplete int
+	Name       string
+}
+
+type ScrapeResponse struct {
+	FailureReason string
+	Files         map[[20]byte]ScrapeFile
+}
+
+func DecodeAnnounceResponse(data []byte) (AnnounceResponse, error) {
+	r := AnnounceResponse{}
+
+	b, err := bencode.Decode(data)
+	if err != nil {
+		return r, err
+	}
+	d, err := b.GetDict()
+	if err != nil {
+		return r, fmt.Errorf("tracker: announce response: %w", err)
+	}
+
+	if r.FailureReason, err = opt.String(d, "failure reason"); err != nil {
+		return r, err
+	}
+	if r.FailureReason != "" {
+		return r, nil
+	}
+
+	if r.Interval, err = b.GetDictInt("interval"); err != nil {
+		return r, fmt.Errorf("tracker: announce response: %w", err)
+	}
+	if r.WarningMessage, err = opt.String(d, "warning message"); err != nil {
+		return r, err
+	}
+	if r.MinInterval, err = opt.Int(d, "min interval"); err != nil {
+		return r, err
+	}
+	if r.TrackerID, err = opt.String(d, "tracker id"); err != nil {
+		return r, err
+	}
+	if r.Complete, err = opt.Int(d, "complete"); err != nil {
+		return r, err
+	}
+	if r.Incomplete, err = opt.Int(d, "incomplete"); err != nil {
+		return r, err
+	}
+
+	if v, ok := d["peers"]; ok {
+		switch v.Type {
+		case bencode.TypeString:
+			s, _ := v.GetString()
+			addrs, err := compact.UnpackPeers(s)
+			if err != nil {
+				return r, fmt.Errorf("tracker: peers: %w", err)
+			}
+			r.Peers = peersFromAddrs(addrs)
+			r.Compact = true
+		case bencode.TypeList:
+			if r.Peers, err = decodePeerList(v); err != nil {
+				return r, err
+			}
+		default:
+			return r, fmt.Errorf("tracker: peers has type %s, expected string or list", v.Type)
+		}
+	}
+
+	if v, ok := d["peers6"]; ok {
+		s, err := v.GetString()
+		if err != nil {
+			return r, fmt.Errorf("tracker: peers6: %w", err)
+		}
+		addrs, err := compact.UnpackPeers6(s)
+		if err != nil {
+			return r, fmt.Errorf("tracker: peers6: %w", err)
+		}
+		r.Peers6 = peersFromAddrs(addrs)
+	}
+
+	return r, nil
+}
+
+func (r AnnounceResponse) Encode() ([]byte, error) {
+	if r.FailureReason != "" {
+		return bencode.EncodeDict(map[string]interface{}{"failure reason": r.FailureReason})
+	}
+
+	d := map[string]interface{}{
+		"interval":   r.Interval,
+		"complete":   r.Complete,
+		"incomplete": r.Incomplete,
+	}
+	if r.WarningMessage != "" {
+		d["warning message"] = r.WarningMessage
+	}
+	if r.MinInterval != 0 {
+		d["min interval"] = r.MinInterval
+	}
+	if r.TrackerID != "" {
+		d["tracker id"] = r.TrackerID
+	}
+
+	if r.Compact {
+		s, err := compact.PackPeers(addrsFromPeers(r.Peers))
+		if err != nil {
+			return nil, fmt.Errorf("tracker: peers: %w", err)
+		}
+		d["peers"] = s
+	} else {
+		l := make([]interface{}, 0, len(r.Peers))
+		for _, p := range r.Peers {
+			ip := p.Host
+			if ip == "" {
+				ip = p.Addr.Addr().Unmap().String()
+			}
+			peer := map[string]interface{}{
+				"ip":   ip,
+				"port": int(p.Addr.Port()),
+			}
+			if p.ID != "" {
+				peer["peer id"] = p.ID
+			}
+			l = append(l, peer)
+		}
+		d["peers"] = l
+	}
+
+	if len(r.Peers6) > 0 {
+		s, err := compact.PackPeers6(addrsFromPeers(r.Peers6))
+		if err != nil {
+			return nil, fmt.Errorf("tracker: peers6: %w", err)
+		}
+		d["peers6"] = s
+	}
+
+	return bencode.EncodeDict(d)
+}
+
+func DecodeScrapeResponse(data []byte) (ScrapeResponse, error) {
+	r := ScrapeResponse{}
+
+	b, err := bencode.Decode(data)
+	if err != nil {
+		return r, err
+	}
+	d, err := b.GetDict()
+	if err != nil {
+		return r, fmt.Errorf("tracker: scrape response: %w", err)
+	}
+
+	if r.FailureReason, err = opt.String(d, "failure reason"); err != nil {
+		return r, err
+	}
+	if r.FailureReason != "" {
+		return r, nil
+	}
+
+	files, err := b.GetDictDict("files")
+	if err != nil {
+		return r, fmt.Errorf("tracker: scrape response: %w", err)
+	}
+
+	r.Files = make(map[[20]byte]ScrapeFile, len(files))
+	for hash, v := range files {
+		if len(hash) != 20 {
+			return r, fmt.Errorf("tracker: scrape info hash has length %d, expected 20", len(hash))
+		}
+		f, err := v.GetDict()
+		if err != nil {
+			return r, fmt.Errorf("tracker: scrape file: %w", err)
+		}
+
+		sf := ScrapeFile{}
+		if sf.Complete, err = opt.Int(f, "complete"); err != nil {
+			return r, err
+		}
+		if sf.Downloaded, err = opt.Int(f, "downloaded"); err != nil {
+			return r, err
+		}
+		if sf.Incomplete, err = opt.Int(f, "incomplete"); err != nil {
+			return r, err
+		}
+		if sf.Name, err = opt.String(f, "name"); err != nil {
+			return r, err
+		}
+		r.Files[[20]byte([]byte(hash))] = sf
+	}
+
+	return r, nil
+}
+
+func (r ScrapeResponse) Encode() ([]byte, error) {
+	if r.FailureReason != "" {
+		return bencode.EncodeDict(map[string]interface{}{"failure reason": r.FailureReason})
+	}
+
+	files := make(map[string]interface{}, len(r.Files))
+	for hash, f := range r.Files {
+		file := map[string]interface{}{
+			"complete":   f.Complete,
+			"downloaded": f.Downloaded,
+			"incomplete": f.Incomplete,
+		}
+		if f.Name != "" {
+			file["name"] = f.Name
+		}
+		files[string(hash[:])] = file
+	}
+
+	return bencode.EncodeDict(map[string]interface{}{"files": files})
+}
+
+func decodePeerList(v bencode.Belement) ([]Peer, error) {
+	l, _ := v.GetList()
+	peers := make([]Peer, 0, len(l))
+	for i := range l {
+		p, err := v.GetListDict(i)
+		if err != nil {
+			return nil, fmt.Errorf("tracker: peer %d: %w", i, err)
+		}
+
+		ip, err := l[i].GetDictString("ip")
+		if err != nil {
+			return nil, fmt.Errorf("tracker: peer %d: %w", i, err)
+		}
+		if ip == "" {
+			return nil, fmt.Errorf("tracker: peer %d: empty ip", i)
+		}
+		// anything that is not an address is taken as a DNS name
+		addr, err := netip.ParseAddr(ip)
+		host := ""
+		if err != nil {
+			host = ip
+		}
+
+		port, err := l[i].GetDictInt("port")
+		if err != nil {
+			return nil, fmt.Errorf("tracker: peer %d: %w", i, err)
+		}
+		if port < 0 || port > 65535 {
+			return nil, fmt.Errorf("tracker: peer %d: port %d out of range", i, port)
+		}
+
+		id, err := opt.String(p, "peer id")
+		if err != nil {
+			return nil, err
+		}
+		peers = append(peers, Peer{ID: id, Addr: netip.AddrPortFrom(addr, uint16(port)), Host: host})
+	}
+	return peers, nil
+}
+
+func peersFromAddrs(addrs []netip.AddrPort) []Peer {
+	peers := make([]Peer, 0, len(addrs))
+	for _, a := range addrs {
+		peers = append(peers, Peer{Addr: a})
+	}
+	return peers
+}
+
+func addrsFromPeers(peers []Peer) []netip.AddrPort {
+	addrs := make([]netip.AddrPort, 0, len(peers))
+	for _, p := range peers {
+		addrs = append(addrs, p.Addr)
+	}
+	return addrs
+}
+
+// opt reads the optional keys of a dict.
+var opt = dictutil.Reader("tracker")
@@ -0,0 +1,153 @@
+package tracker_test
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/deathcrafter/bencode/tracker"
+)
+
+func TestAnnounceCompact(t *testing.T) {
+	data := "d8:completei5e10:incompletei2e8:intervali1800e5:peers6:\x0a\x00\x00\x01\x1a\xe1" +
+		"6:peers618:\x20\x01\x0d\xb8\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x01\x1a\xe1e"
+	r, err := tracker.DecodeAnnounceResponse([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.Interval != 1800 || r.Complete != 5 || r.Incomplete != 2 {
+		t.Fatalf("Unexpected counters %+v", r)
+	}
+	if !r.Compact || len(r.Peers) != 1 || r.Peers[0].Addr != netip.MustParseAddrPort("10.0.0.1:6881") {
+		t.Fatalf("Unexpected peers %v", r.Peers)
+	}
+	if len(r.Peers6) != 1 || r.Peers6[0].Addr != netip.MustParseAddrPort("[2001:db8::1]:6881") {
+		t.Fatalf("Unexpected peers6 %v", r.Peers6)
+	}
+
+	e, err := r.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(e) != data {
+		t.Fatalf("Expected %q, got %q", data, string(e))
+	}
+}
+
+func TestAnnounceTrailingNewline(t *testing.T) {
+	r, err := tracker.DecodeAnnounceResponse([]byte("d8:intervali1800e5:peers0:e\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Interval != 1800 {
+		t.Fatalf("Unexpected interval %d", r.Interval)
+	}
+}
+
+func TestAnnounceDictPeerHost(t *testing.T) {
+	data := "d8:completei0e10:incompletei0e8:intervali900e5:peersld2:ip16:peer.example.org4:porti6881eed2:ip8:10.0.0.24:porti51413eeee"
+	r, err := tracker.DecodeAnnounceResponse([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Peers) != 2 || r.Peers[0].Host != "peer.example.org" || r.Peers[0].Addr.Port() != 6881 {
+		t.Fatalf("Unexpected peers %+v", r.Peers)
+	}
+	if r.Peers[1].Host != "" || r.Peers[1].Addr != netip.MustParseAddrPort("10.0.0.2:51413") {
+		t.Fatalf("Unexpected peers %+v", r.Peers)
+	}
+
+	e, err := r.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(e) != data {
+		t.Fatalf("Expected %q, got %q", data, e)
+	}
+}
+
+func TestAnnounceDictPeers(t *testing.T) {
+	r := tracker.AnnounceResponse{
+		Interval: 900,
+		Peers: []tracker.Peer{
+			{ID: "-XX0001-abcdefghijkl", Addr: netip.MustParseAddrPort("10.0.0.2:51413")},
+		},
+	}
+	e, err := r.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "d8:completei0e10:incompletei0e8:intervali900e5:peersld2:ip8:10.0.0.27:peer id20:-XX0001-abcdefghijkl4:porti51413eeee"
+	if string(e) != expected {
+		t.Fatalf("Expected %s, got %s", expected, string(e))
+	}
+
+	d, err := tracker.DecodeAnnounceResponse(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Compact || len(d.Peers) != 1 || d.Peers[0] != r.Peers[0] {
+		t.Fatalf("Expected %v, got %v", r.Peers, d.Peers)
+	}
+}
+
+func TestAnnounceFailure(t *testing.T) {
+	r, err := tracker.DecodeAnnounceResponse([]byte("d14:failure reason12:unregisterede"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.FailureReason != "unregistered" {
+		t.Fatalf("Expected %s, got %s", "unregistered", r.FailureReason)
+	}
+}
+
+func TestAnnounceInvalid(t *testing.T) {
+	for _, data := range []string{
+		"de",
+		"d8:intervali1e5:peers5:abcdee",
+		"d8:intervali1e5:peersi1ee",
+		"d8:intervali1e5:peersld2:ip0:4:porti1eeee",
+	} {
+		if _, err := tracker.DecodeAnnounceResponse([]byte(data)); err == nil {
+			t.Fatalf("Expected error for %q, got nil", data)
+		} else {
+			t.Log(err)
+		}
+	}
+}
+
+func TestScrape(t *testing.T) {
+	hash := [20]byte{}
+	copy(hash[:], strings.Repeat("\xaa", 20))
+
+	r := tracker.ScrapeResponse{Files: map[[20]byte]tracker.ScrapeFile{
+		hash: {Complete: 3, Downloaded: 10, Incomplete: 1},
+	}}
+	e, err := r.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "d5:filesd20:" + strings.Repeat("\xaa", 20) + "d8:completei3e10:downloadedi10e10:incompletei1eeee"
+	if string(e) != expected {
+		t.Fatalf("Expected %q, got %q", expected, string(e))
+	}
+
+	d, err := tracker.DecodeScrapeResponse(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Files[hash] != r.Files[hash] {
+		t.Fatalf("Expected %v, got %v", r.Files[hash], d.Files[hash])
+	}
+}
+
+func TestScrapeInvalidHash(t *testing.T) {
+	_, err := tracker.DecodeScrapeResponse([]byte("d5:filesd3:abcdeee"))
+	if err == nil {
+		t.Fatal("Expected error, got nil")
+	}
+	t.Log(err)
+}
@@ -21,3 +21,12 @@ func (e BencodeError) Error() string {
 type Source interface {
 	Read(b []byte) (n int, err error)
 }
+
+type SyntaxError struct {
+	Offset int
+	msg    string
+}
+
+func (e SyntaxError) Error() string {
+	return fmt.Sprintf("%s at offset %d", e.msg, e.Offset)
+}
@@ -0,0 +1,396 @@
+package bencode
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+)
+
+// Unmarshal decodes data into the value pointed to by v, following the
+// mapping of Marshal. Ints decode into bools as non-zero, an empty interface
+// receives int, string, []interface{} or map[string]interface{} values, and
+// a Belement field receives the element as is.
+//
+// For struct fields, a missing key leaves the field untouched unless the
+// field is tagged required, which is an error, or has a default.
+//
+// Dict keys that match no struct field are stored in the field tagged extra
+// when there is one, and ignored otherwise. Use UnmarshalOptions to reject
+// them instead.
+func Unmarshal(data []byte, v interface{}) error {
+	return UnmarshalOptions{}.Unmarshal(data, v)
+}
+
+// Unmarshaler is implemented by types that decode themselves, such as those
+// generated by cmd/bencodegen. UnmarshalBencode receives the encoding of a
+// single element. UnmarshalOptions do not apply to it.
+type Unmarshaler interface {
+	UnmarshalBencode(data []byte) error
+}
+
+var unmarshalerType = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+
+// UnmarshalBelement stores an already decoded element in v like Unmarshal.
+func UnmarshalBelement(b Belement, v interface{}) error {
+	return UnmarshalOptions{}.UnmarshalBelement(b, v)
+}
+
+type UnmarshalOptions struct {
+	// DisallowUnknownFields makes dict keys that match no struct field an
+	// error, unless the struct has an extra field to hold them.
+	DisallowUnknownFields bool
+}
+
+func (o UnmarshalOptions) Unmarshal(data []byte, v interface{}) error {
+	// an Unmarshaler reads data itself, without building Belements first
+	if u, ok := v.(Unmarshaler); ok {
+		if rv := reflect.ValueOf(v); rv.Kind() == reflect.Pointer && !rv.IsNil() {
+			return u.UnmarshalBencode(data)
+		}
+	}
+	b, err := Decode(data)
+	if err != nil {
+		return err
+	}
+	return o.UnmarshalBelement(b, v)
+}
+
+func (o UnmarshalOptions) UnmarshalBelement(b Belement, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return BencodeError{msg: fmt.Sprintf("Unmarshal requires a non-nil pointer, got %T", v)}
+	}
+	rv = rv.Elem()
+	return publicError(typeDecoder(rv.Type())(o, b, rv))
+}
+
+func mismatch(b Belement, rv reflect.Value) error {
+	return newPathError("cannot unmarshal %s into %s", b.Type, rv.Type())
+}
+
+func newTypeDecoder(t reflect.Type) decoderFunc {
+	if t == belementType {
+		return decodeBelement
+	}
+	if t.Kind() != reflect.Interface && reflect.PointerTo(t).Implements(unmarshalerType) {
+		return decodeUnmarshaler
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		elem := typeDecoder(t.Elem())
+		return func(o UnmarshalOptions, b Belement, rv reflect.Value) error {
+			if rv.IsNil() {
+				rv.Set(reflect.New(t.Elem()))
+			}
+			return elem(o, b, rv.Elem())
+		}
+	case reflect.Interface:
+		return decodeInterface
+	case reflect.Bool:
+		return decodeBool
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return decodeInt
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return decodeUint
+	case reflect.String:
+		return decodeString
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return decodeBytes
+		}
+		return newSliceDecoder(t)
+	case reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return decodeByteArray
+		}
+		return newArrayDecoder(t)
+	case reflect.Map:
+		return newMapDecoder(t)
+	case reflect.Struct:
+		return newStructDecoder(t)
+	default:
+		return func(o UnmarshalOptions, b Belement, rv reflect.Value) error {
+			return newPathError("cannot unmarshal into %s", t)
+		}
+	}
+}
+
+func decodeBelement(o UnmarshalOptions, b Belement, rv reflect.Value) error {
+	*rv.Addr().Interface().(*Belement) = b
+	return nil
+}
+
+func decodeUnmarshaler(o UnmarshalOptions, b Belement, rv reflect.Value) error {
+	data, err := b.Encode()
+	if err != nil {
+		return err
+	}
+	return rv.Addr().Interface().(Unmarshaler).UnmarshalBencode(data)
+}
+
+func decodeInterface(o UnmarshalOptions, b Belement, rv reflect.Value) error {
+	if rv.NumMethod() != 0 {
+		return mismatch(b, rv)
+	}
+	x, err := nativeValue(b)
+	if err != nil {
+		return err
+	}
+	rv.Set(reflect.ValueOf(x))
+	return nil
+}
+
+func decodeBool(o UnmarshalOptions, b Belement, rv reflect.Value) error {
+	i, err := b.GetInt()
+	if err != nil {
+		return mismatch(b, rv)
+	}
+	rv.SetBool(i != 0)
+	return nil
+}
+
+func decodeInt(o UnmarshalOptions, b Belement, rv reflect.Value) error {
+	i, err := b.GetInt()
+	if err != nil {
+		return mismatch(b, rv)
+	}
+	if rv.OverflowInt(int64(i)) {
+		return newPathError("value %d overflows %s", i, rv.Type())
+	}
+	rv.SetInt(int64(i))
+	return nil
+}
+
+func decodeUint(o UnmarshalOptions, b Belement, rv reflect.Value) error {
+	i, err := b.GetInt()
+	if err != nil {
+		return mismatch(b, rv)
+	}
+	if i < 0 || rv.OverflowUint(uint64(i)) {
+		return newPathError("value %d overflows %s", i, rv.Type())
+	}
+	rv.SetUint(uint64(i))
+	return nil
+}
+
+func decodeString(o UnmarshalOptions, b Belement, rv reflect.Value) error {
+	s, err := b.GetString()
+	if err != nil {
+		return mismatch(b, rv)
+	}
+	rv.SetString(s)
+	return nil
+}
+
+func decodeBytes(o UnmarshalOptions, b Belement, rv reflect.Value) error {
+	s, err := b.GetString()
+	if err != nil {
+		return mismatch(b, rv)
+	}
+	rv.SetBytes([]byte(s))
+	return nil
+}
+
+func decodeByteArray(o UnmarshalOptions, b Belement, rv reflect.Value) error {
+	s, err := b.GetString()
+	if err != nil {
+		return mismatch(b, rv)
+	}
+	if len(s) != rv.Len() {
+		return newPathError("string of length %d does not fit %s", len(s), rv.Type())
+	}
+	reflect.Copy(rv, reflect.ValueOf([]byte(s)))
+	return nil
+}
+
+func newSliceDecoder(t reflect.Type) decoderFunc {
+	elem := typeDecoder(t.Elem())
+	return func(o UnmarshalOptions, b Belement, rv reflect.Value) error {
+		l, err := b.GetList()
+		if err != nil {
+			return mismatch(b, rv)
+		}
+		x := reflect.MakeSlice(t, len(l), len(l))
+		for i, e := range l {
+			if err := elem(o, e, x.Index(i)); err != nil {
+				return atIndex(err, i)
+			}
+		}
+		rv.Set(x)
+		return nil
+	}
+}
+
+func newArrayDecoder(t reflect.Type) decoderFunc {
+	elem := typeDecoder(t.Elem())
+	return func(o UnmarshalOptions, b Belement, rv reflect.Value) error {
+		l, err := b.GetList()
+		if err != nil {
+			return mismatch(b, rv)
+		}
+		if len(l) != rv.Len() {
+			return newPathError("list of length %d does not fit %s", len(l), rv.Type())
+		}
+		for i, e := range l {
+			if err := elem(o, e, rv.Index(i)); err != nil {
+				return atIndex(err, i)
+			}
+		}
+		return nil
+	}
+}
+
+func newMapDecoder(t reflect.Type) decoderFunc {
+	elem := typeDecoder(t.Elem())
+	return func(o UnmarshalOptions, b Belement, rv reflect.Value) error {
+		d, err := b.GetDict()
+		if err != nil {
+			return mismatch(b, rv)
+		}
+		if rv.IsNil() {
+			rv.Set(reflect.MakeMapWithSize(t, len(d)))
+		}
+		// SetMapIndex copies, so one key and value serve every entry
+		key := reflect.New(t.Key()).Elem()
+		x := reflect.New(t.Elem()).Elem()
+		for k, e := range d {
+			key.SetZero()
+			if err := setMapKey(key, k); err != nil {
+				return err
+			}
+			x.SetZero()
+			if err := elem(o, e, x); err != nil {
+				return atKey(err, k)
+			}
+			rv.SetMapIndex(key, x)
+		}
+		return nil
+	}
+}
+
+func newStructDecoder(t reflect.Type) decoderFunc {
+	fields, err := typeFields(t)
+	if err != nil {
+		return func(o UnmarshalOptions, b Belement, rv reflect.Value) error {
+			return err
+		}
+	}
+	decoders := make([]decoderFunc, len(fields.list))
+	for i, f := range fields.list {
+		decoders[i] = typeDecoder(t.FieldByIndex(f.index).Type)
+	}
+
+	return func(o UnmarshalOptions, b Belement, rv reflect.Value) error {
+		d, err := b.GetDict()
+		if err != nil {
+			return mismatch(b, rv)
+		}
+		found := 0
+		for i, f := range fields.list {
+			e, ok := d[f.key]
+			switch {
+			case ok:
+				found++
+				if err := decoders[i](o, e, allocFieldByIndex(rv, f.index)); err != nil {
+					return atKey(err, f.key)
+				}
+			case f.required:
+				return atKey(newPathError("missing required key"), f.key)
+			case f.def.IsValid():
+				allocFieldByIndex(rv, f.index).Set(f.def)
+			}
+		}
+
+		// every key was a field, so there is nothing left to look at
+		if found == len(d) {
+			if fields.extra != nil {
+				if fv, ok := fieldByIndex(rv, fields.extra.index); ok {
+					fv.SetZero()
+				}
+			}
+			return nil
+		}
+
+		var extra map[string]Belement
+		for k, e := range d {
+			if _, ok := fields.byKey(k); ok {
+				continue
+			}
+			switch {
+			case fields.extra != nil:
+				if extra == nil {
+					extra = make(map[string]Belement, len(d)-found)
+				}
+				extra[k] = e
+			case o.DisallowUnknownFields:
+				return atKey(newPathError("unknown key for %s", t), k)
+			}
+		}
+		if fields.extra != nil {
+			allocFieldByIndex(rv, fields.extra.index).Set(reflect.ValueOf(extra))
+		}
+		return nil
+	}
+}
+
+// setMapKey is the inverse of mapKey.
+func setMapKey(key reflect.Value, k string) error {
+	if key.Kind() == reflect.String {
+		key.SetString(k)
+		return nil
+	}
+	if u, ok := key.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		if err := u.UnmarshalText([]byte(k)); err != nil {
+			return atKey(newPathError("cannot unmarshal key into %s: %s", key.Type(), err.Error()), k)
+		}
+		return nil
+	}
+	if key.Kind() == reflect.Array && key.Type().Elem().Kind() == reflect.Uint8 {
+		if len(k) != key.Len() {
+			return atKey(newPathError("key of length %d does not fit %s", len(k), key.Type()), k)
+		}
+		reflect.Copy(key, reflect.ValueOf([]byte(k)))
+		return nil
+	}
+	return newPathError("cannot unmarshal into map with %s keys", key.Type())
+}
+
+func nativeValue(b Belement) (interface{}, error) {
+	switch b.Type {
+	case TypeInt:
+		return b.GetInt()
+	case TypeString:
+		return b.GetString()
+	case TypeList:
+		l, err := b.GetList()
+		if err != nil {
+			return nil, err
+		}
+		x := make([]interface{}, 0, len(l))
+		for _, e := range l {
+			v, err := nativeValue(e)
+			if err != nil {
+				return nil, err
+			}
+			x = append(x, v)
+		}
+		return x, nil
+	case TypeDict:
+		d, err := b.GetDict()
+		if err != nil {
+			return nil, err
+		}
+		x := make(map[string]interface{}, len(d))
+		for k, e := range d {
+			v, err := nativeValue(e)
+			if err != nil {
+				return nil, err
+			}
+			x[k] = v
+		}
+		return x, nil
+	default:
+		return nil, BencodeError{msg: "Belement is invalid"}
+	}
+}